@@ -0,0 +1,224 @@
+package AVL_Tree
+
+import (
+	"bytes"
+	"slices"
+	"sort"
+)
+
+//Batch of changes written to a tree together. Only the last change to each key is held,
+// and the changes are merged into the tree in a single ordered pass, so each changed subtree
+// is rebalanced once rather than the path to the trunk being rebalanced for every change.
+// As hashing is deferred until the hash is read, each changed node is hashed once per batch.
+type Batch struct {
+	tree  *AVLTree
+	ops   []batchOp
+	index map[string]int //position of the change held for each key within ops
+}
+
+//Change held by a batch, removing the key if remove is true
+type batchOp struct {
+	key    []byte
+	value  []byte
+	remove bool
+}
+
+//Create a new empty batch of changes to the tree
+func (t *AVLTree) NewBatch() *Batch {
+	return &Batch{
+		tree:  t,
+		index: make(map[string]int),
+	}
+}
+
+//Set the value for the key when the batch is written
+func (b *Batch) Set(key, value []byte) {
+	b.add(batchOp{key: key, value: value})
+}
+
+//Remove the key when the batch is written, if it exists
+func (b *Batch) Delete(key []byte) {
+	b.add(batchOp{key: key, remove: true})
+}
+
+//Hold the change, replacing the change already held for its key if any
+func (b *Batch) add(op batchOp) {
+	if i, ok := b.index[string(op.key)]; ok {
+		b.ops[i] = op
+		return
+	}
+	b.index[string(op.key)] = len(b.ops)
+	b.ops = append(b.ops, op)
+}
+
+//Returns the number of keys changed by the batch
+func (b *Batch) Len() int {
+	return len(b.ops)
+}
+
+//Write the changes to the tree, the batch is then empty and may be reused.
+func (b *Batch) Write() (err error) {
+	t := b.tree
+
+	ops := b.ops
+	b.ops = nil
+	clear(b.index)
+	slices.SortFunc(ops, func(a, b batchOp) int {
+		return bytes.Compare(a.key, b.key)
+	})
+
+	if err = t.storeErr(); err != nil {
+		return
+	}
+	defer t.finishStore(&err)
+
+	trunk, changed := t.trunk.merge(t, ops)
+	if !changed {
+		return nil
+	}
+
+	trunk.record(t)
+	trunk.parNode = nil
+	t.trunk = trunk
+
+	t.mutations++
+
+	return nil
+}
+
+//Merge the sorted changes into the subtree of the node, returning the root of the
+// resulting subtree and whether it was changed. The subtrees either side of the node
+// are merged first, then joined back together about the node. The parent of the
+// returned root must be set by the caller.
+// The tree (tr) must be passed in to record and rebalance the changed nodes.
+func (n *node) merge(tr *AVLTree, ops []batchOp) (root *node, changed bool) {
+
+	if len(ops) == 0 {
+		return n, false
+	}
+
+	//A placeholder is replaced by a balanced subtree of the keys being set,
+	// removing a key which does not exist leaves the tree unchanged
+	if n.isPlaceholder() {
+		sets := ops[:0]
+		for _, op := range ops {
+			if !op.remove {
+				sets = append(sets, op)
+			}
+		}
+		if len(sets) == 0 {
+			return n, false
+		}
+		return buildBalanced(tr, sets), true
+	}
+
+	//Split the changes about the node's key
+	i := sort.Search(len(ops), func(i int) bool {
+		return bytes.Compare(ops[i].key, n.key) >= 0
+	})
+	var match *batchOp
+	j := i
+	if i < len(ops) && bytes.Equal(ops[i].key, n.key) {
+		match = &ops[i]
+		j++
+	}
+
+	left, leftChanged := n.leftNode.merge(tr, ops[:i])
+	right, rightChanged := n.rightNode.merge(tr, ops[j:])
+
+	switch {
+	case match != nil && match.remove:
+		n.markUnsaved(tr)
+		return joinSubtrees(tr, left, right), true
+	case match != nil:
+		n.record(tr)
+		n.value = match.value
+	case !leftChanged && !rightChanged:
+		return n, false
+	}
+
+	return n.join(tr, left, right), true
+}
+
+//Build a balanced subtree holding the sorted changes, returning its root
+func buildBalanced(tr *AVLTree, ops []batchOp) *node {
+	if len(ops) == 0 {
+		return newNodePlaceholder(nil)
+	}
+
+	mid := len(ops) / 2
+	n := newNodeLeaf(tr, nil, ops[mid].key, ops[mid].value)
+	return n.setChildren(tr, buildBalanced(tr, ops[:mid]), buildBalanced(tr, ops[mid+1:]))
+}
+
+//Returns the height of the subtree, with an empty subtree one lower than a leaf
+func (n *node) subtreeHeight() int {
+	if n.isPlaceholder() {
+		return -1
+	}
+	return n.height
+}
+
+//Join the subtrees with the node between them, returning the root of the joined subtree.
+// All keys of left must be less than the node's key and all keys of right greater.
+// Where the subtree heights differ the node is joined down the side of the taller
+// subtree, so only the nodes along that side are rebalanced.
+func (n *node) join(tr *AVLTree, left, right *node) *node {
+	leftHeight := left.subtreeHeight()
+	rightHeight := right.subtreeHeight()
+
+	switch {
+	case leftHeight > rightHeight+2:
+		return left.setChildren(tr, left.leftNode, n.join(tr, left.rightNode, right))
+	case rightHeight > leftHeight+2:
+		return right.setChildren(tr, n.join(tr, left, right.leftNode), right.rightNode)
+	}
+	return n.setChildren(tr, left, right)
+}
+
+//Join two subtrees, returning the root of the joined subtree.
+// All keys of left must be less than all keys of right.
+func joinSubtrees(tr *AVLTree, left, right *node) *node {
+	if right.isPlaceholder() {
+		return left
+	}
+	right, min := right.removeMin(tr)
+	return min.join(tr, left, right)
+}
+
+//Remove the minimum node from the subtree, returning the root of the remaining
+// subtree and the removed node
+func (n *node) removeMin(tr *AVLTree) (root, min *node) {
+	if n.leftNode.isPlaceholder() {
+		return n.rightNode, n
+	}
+	left, min := n.leftNode.removeMin(tr)
+	return n.setChildren(tr, left, n.rightNode), min
+}
+
+//Set the children of the node and rebalance it, returning the root of the rebalanced
+// subtree. The heights of the children must differ by no more than two.
+// The node is detached from its parent, which must be set by the caller.
+func (n *node) setChildren(tr *AVLTree, left, right *node) *node {
+	n.record(tr)
+	n.parNode = nil
+	n.leftNode = left
+	n.rightNode = right
+	if left.parNode != n {
+		left.record(tr)
+		left.parNode = n
+	}
+	if right.parNode != n {
+		right.record(tr)
+		right.parNode = n
+	}
+
+	//A rotation of the detached node treats it as the trunk, so the
+	// rebalanced root is the node left without a parent
+	n.updateHeightAndHash(tr)
+	n.updateBalance(tr)
+	for n.parNode != nil {
+		n = n.parNode
+	}
+	return n
+}
@@ -0,0 +1,263 @@
+package AVL_Tree
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"golang.org/x/crypto/sha3"
+)
+
+func TestBatch(t *testing.T) {
+
+	printErr := func(err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//Count the nodes hashed by the batched tree
+	hashes := 0
+	countingHasher := HasherFunc(func(input []byte) []byte {
+		hashes++
+		hashBytes := sha3.Sum256(input)
+		return hashBytes[:]
+	})
+
+	//The tree changed with batches, and the expected records held within it
+	tr := NewAVLTree(WithHasher(countingHasher))
+	records := make(map[string]string)
+
+	//Recompute the height and hash of a node from scratch,
+	// checking the stored values, ordering, balance, and parent links along the way
+	var recompute func(tr *AVLTree, n *node, lo, hi []byte) (height int, hash []byte)
+	recompute = func(tr *AVLTree, n *node, lo, hi []byte) (height int, hash []byte) {
+		if n.isPlaceholder() {
+			return -1, nil
+		}
+
+		if (lo != nil && bytes.Compare(n.key, lo) <= 0) || (hi != nil && bytes.Compare(n.key, hi) >= 0) {
+			t.Errorf("bad order for %v", string(n.key))
+		}
+		if n.leftNode.parNode != n || n.rightNode.parNode != n {
+			t.Errorf("bad parent link for a child of %v", string(n.key))
+		}
+
+		leftHeight, leftHash := recompute(tr, n.leftNode, lo, n.key)
+		rightHeight, rightHash := recompute(tr, n.rightNode, n.key, hi)
+
+		height = max(leftHeight, rightHeight) + 1
+
+		if bal := rightHeight - leftHeight; bal > 1 || bal < -1 {
+			t.Errorf("bad balance for %v found %v", string(n.key), bal)
+		}
+		if n.height != height {
+			t.Errorf("bad height for %v, expected %v found %v", string(n.key), height, n.height)
+		}
+		if size := n.leftNode.size + n.rightNode.size + 1; n.size != size {
+			t.Errorf("bad size for %v, expected %v found %v", string(n.key), size, n.size)
+		}
+
+		hash = tr.config.hashNode(n.key, n.value, height, leftHash, rightHash)
+		if !bytes.Equal(n.hash, hash) {
+			t.Errorf("bad hash for %v", string(n.key))
+		}
+		return
+	}
+
+	//Test the tree structure and the tree contents against the expected records
+	checkTree := func(tr *AVLTree, records map[string]string, op string) {
+		if !tr.trunk.isTrunk() {
+			t.Fatalf("bad trunk after %v", op)
+		}
+		recompute(tr, tr.trunk, nil, nil)
+
+		for key, value := range records {
+			recievedVal, err := tr.Get([]byte(key))
+			if err != nil || string(recievedVal) != value {
+				t.Fatalf("bad value for %v after %v, expected %v found %v", key, op, value, string(recievedVal))
+			}
+		}
+
+		if tr.Size() != len(records) {
+			t.Fatalf("bad size after %v, expected %v found %v", op, len(records), tr.Size())
+		}
+	}
+
+	//Batches of random changes, from small changes to a large tree through to
+	// large changes which remove most of the tree or rebuild it
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		batch := tr.NewBatch()
+		changes := make(map[string]bool)
+		count := rnd.Intn(1 << uint(rnd.Intn(10)))
+		removeOdds := 1 + rnd.Intn(4)
+		for j := 0; j < count; j++ {
+			key := fmt.Sprintf("k%03d", rnd.Intn(1000))
+			changes[key] = true
+			if rnd.Intn(removeOdds) == 0 {
+				batch.Delete([]byte(key))
+				delete(records, key)
+			} else {
+				value := fmt.Sprintf("v%d-%d", i, j)
+				batch.Set([]byte(key), []byte(value))
+				records[key] = value
+			}
+		}
+
+		//Only the last change to each key is held
+		if batch.Len() != len(changes) {
+			t.Errorf("bad batch length, expected %v found %v", len(changes), batch.Len())
+		}
+
+		hashes = 0
+		printErr(batch.Write())
+		if batch.Len() != 0 {
+			t.Errorf("expected an empty batch after writing, found %v changes", batch.Len())
+		}
+
+		//Each changed node is hashed once at most, when the hash is read
+		tr.GetHash()
+		if hashes > tr.Size() {
+			t.Errorf("bad hash count for batch %v, expected at most %v found %v", i, tr.Size(), hashes)
+		}
+
+		checkTree(&tr, records, fmt.Sprintf("batch %v", i))
+	}
+
+	//Test rolling back a batch
+	hash, err := tr.Save()
+	printErr(err)
+	batch := tr.NewBatch()
+	for i := 0; i < 1000; i += 2 {
+		batch.Delete([]byte(fmt.Sprintf("k%03d", i)))
+		batch.Set([]byte(fmt.Sprintf("k%03d", i+1)), []byte("changed"))
+	}
+	printErr(batch.Write())
+	printErr(tr.Rollback())
+	rolledBackHash, _ := tr.GetHash()
+	if !bytes.Equal(hash, rolledBackHash) {
+		t.Errorf("bad hash after rolling back a batch, expected %x found %x", hash, rolledBackHash)
+	}
+	checkTree(&tr, records, "rollback")
+
+	//Test batches written to a tree with a node database
+	db := NewMemNodeDB()
+	dbTr := NewAVLTree(WithNodeDB(db), WithCacheSize(8))
+	batch = dbTr.NewBatch()
+	for key, value := range records {
+		batch.Set([]byte(key), []byte(value))
+	}
+	printErr(batch.Write())
+	_, err = dbTr.Save()
+	printErr(err)
+
+	dbTr, err = LoadAVLTree(WithNodeDB(db), WithCacheSize(8))
+	printErr(err)
+	batch = dbTr.NewBatch()
+	for i := 0; i < 1000; i += 3 {
+		key := fmt.Sprintf("k%03d", i)
+		if i%2 == 0 {
+			batch.Delete([]byte(key))
+			delete(records, key)
+		} else {
+			batch.Set([]byte(key), []byte("changed"))
+			records[key] = "changed"
+		}
+	}
+	printErr(batch.Write())
+	_, err = dbTr.Save()
+	printErr(err)
+
+	dbTr, err = LoadAVLTree(WithNodeDB(db), WithCacheSize(8))
+	printErr(err)
+	checkTree(&dbTr, records, "node database batch")
+}
+
+func BenchmarkBatchWrite(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		tr := NewAVLTree()
+		batch := tr.NewBatch()
+		for j := 0; j < 100000; j++ {
+			batch.Set([]byte(fmt.Sprintf("k%08d", (j*7919)%100000)), []byte("value"))
+		}
+		if err := batch.Write(); err != nil {
+			b.Fatal(err)
+		}
+		tr.GetHash()
+	}
+}
+
+func BenchmarkSequentialSet(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		tr := NewAVLTree()
+		for j := 0; j < 100000; j++ {
+			if err := tr.Set([]byte(fmt.Sprintf("k%08d", (j*7919)%100000)), []byte("value")); err != nil {
+				b.Fatal(err)
+			}
+		}
+		tr.GetHash()
+	}
+}
+
+//Trees with a node database are changed with a small cache, where a batch loads each
+// node on the paths to the changed keys once rather than loading a path for each change
+func benchmarkNodeDBWrite(b *testing.B, batched bool) {
+	db := &countingNodeDB{NodeDB: NewMemNodeDB()}
+	tr := NewAVLTree(WithNodeDB(db))
+	for j := 0; j < 100000; j++ {
+		tr.Add([]byte(fmt.Sprintf("k%08d", j)), []byte("value"))
+	}
+	if _, err := tr.Save(); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	db.gets = 0
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		tr, err := LoadAVLTree(WithNodeDB(db), WithCacheSize(1000))
+		if err != nil {
+			b.Fatal(err)
+		}
+		batch := tr.NewBatch()
+		b.StartTimer()
+
+		for j := 0; j < 10000; j++ {
+			key := []byte(fmt.Sprintf("k%08d", (j*7919)%100000))
+			if batched {
+				batch.Set(key, []byte("changed"))
+			} else if err := tr.Set(key, []byte("changed")); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if err := batch.Write(); err != nil {
+			b.Fatal(err)
+		}
+		tr.GetHash()
+	}
+	b.ReportMetric(float64(db.gets)/float64(b.N), "loads/op")
+}
+
+//Node database which counts the records read from it
+type countingNodeDB struct {
+	NodeDB
+	gets int
+}
+
+func (db *countingNodeDB) Get(id uint64) ([]byte, error) {
+	db.gets++
+	return db.NodeDB.Get(id)
+}
+
+func BenchmarkBatchWriteNodeDB(b *testing.B) {
+	benchmarkNodeDBWrite(b, true)
+}
+
+func BenchmarkSequentialSetNodeDB(b *testing.B) {
+	benchmarkNodeDBWrite(b, false)
+}
@@ -0,0 +1,64 @@
+package AVL_Tree
+
+import (
+	"bytes"
+	"errors"
+	"iter"
+)
+
+//errors used when building a tree from records which are not sorted, or hold a nil key
+// which would be taken for a placeholder node
+var errUnsorted error = errors.New("Keys are not in ascending order")
+var errNilKey error = errors.New("Nil key found")
+
+//Build a perfectly balanced tree from the key-value pairs in ascending key order, in O(n)
+// time rather than adding each record. The middle record of each range becomes the node
+// holding the records either side of it, and heights, sizes, and hashes are computed bottom-up.
+// The keys and values are copied, so the caller may reuse their buffers.
+// Generates an error if the keys are not in ascending order, a key is repeated, or a key is nil.
+// Options may be passed in to configure the tree.
+func BuildFromSorted(records iter.Seq2[[]byte, []byte], opts ...Option) (tr AVLTree, err error) {
+
+	tr = NewAVLTree(opts...)
+
+	//Read the records into unlinked nodes, checking their order
+	var nodes []*node
+	for key, value := range records {
+		if key == nil {
+			return tr, errNilKey
+		}
+		if len(nodes) > 0 {
+			switch bytes.Compare(key, nodes[len(nodes)-1].key) {
+			case 0:
+				return tr, errDupVal
+			case -1:
+				return tr, errUnsorted
+			}
+		}
+		nodes = append(nodes, &node{key: bytes.Clone(key), value: bytes.Clone(value)})
+	}
+
+	//Link the nodes of the range lo to hi (inclusive) under the parent
+	var link func(parNode *node, lo, hi int) *node
+	link = func(parNode *node, lo, hi int) *node {
+		if lo > hi {
+			return newNodePlaceholder(parNode)
+		}
+
+		mid := lo + (hi-lo)/2
+		n := nodes[mid]
+		n.parNode = parNode
+		n.leftNode = link(n, lo, mid-1)
+		n.rightNode = link(n, mid+1, hi)
+		n.updateHeight()
+		n.updateSize()
+
+		return n
+	}
+	tr.trunk = link(nil, 0, len(nodes)-1)
+
+	//All nodes are marked to be hashed as their hash is nil
+	tr.trunk.rehash(&tr)
+
+	return tr, nil
+}
@@ -0,0 +1,216 @@
+package AVL_Tree
+
+import (
+	"bytes"
+	"fmt"
+	"math/bits"
+	"testing"
+)
+
+func TestBuildFromSorted(t *testing.T) {
+
+	printErr := func(err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//Sequence over the records k0000 to k(n-1) in order
+	sortedRecords := func(n int) func(yield func(key, value []byte) bool) {
+		return func(yield func(key, value []byte) bool) {
+			for i := 0; i < n; i++ {
+				key := fmt.Sprintf("k%04d", i)
+				if !yield([]byte(key), []byte("v"+key)) {
+					return
+				}
+			}
+		}
+	}
+
+	//Check the parent links, heights, sizes, and balance of each node against a
+	// full recomputation, returning the recomputed height and hash of the node
+	var recompute func(tr *AVLTree, n *node) (height int, hash []byte)
+	recompute = func(tr *AVLTree, n *node) (height int, hash []byte) {
+		if n.isPlaceholder() {
+			return -1, nil
+		}
+		if n.leftNode.parNode != n || n.rightNode.parNode != n {
+			t.Fatalf("bad parent link for a child of %v", string(n.key))
+		}
+
+		leftHeight, leftHash := recompute(tr, n.leftNode)
+		rightHeight, rightHash := recompute(tr, n.rightNode)
+		height = max(leftHeight, rightHeight) + 1
+
+		if bal := rightHeight - leftHeight; bal < -1 || bal > 1 {
+			t.Fatalf("bad balance for %v found %v", string(n.key), bal)
+		}
+		if n.height != height || n.size != n.leftNode.size+n.rightNode.size+1 {
+			t.Fatalf("bad height or size for %v", string(n.key))
+		}
+
+		hash = tr.config.hashNode(n.key, n.value, height, leftHash, rightHash)
+		if !bytes.Equal(n.hash, hash) {
+			t.Fatalf("bad hash for %v", string(n.key))
+		}
+		return
+	}
+
+	for n := 0; n < 300; n++ {
+		tr, err := BuildFromSorted(sortedRecords(n))
+		printErr(err)
+
+		if n == 0 {
+			if _, err = tr.GetHash(); err != errEmptyTree {
+				t.Errorf("expected an empty tree error, found %v", err)
+			}
+			continue
+		}
+		if !tr.trunk.isTrunk() {
+			t.Fatalf("bad trunk for %v records", n)
+		}
+
+		//A perfectly balanced tree has the least height possible
+		if expdHeight := bits.Len(uint(n)) - 1; tr.trunk.height != expdHeight {
+			t.Errorf("bad height for %v records, expected %v found %v", n, expdHeight, tr.trunk.height)
+		}
+		recompute(&tr, tr.trunk)
+		if tr.Size() != n {
+			t.Errorf("bad size, expected %v found %v", n, tr.Size())
+		}
+
+		for i := 0; i < n; i++ {
+			key := fmt.Sprintf("k%04d", i)
+			value, err := tr.Get([]byte(key))
+			if err != nil || string(value) != "v"+key {
+				t.Errorf("bad value for %v, found %s %v", key, value, err)
+			}
+		}
+	}
+
+	//Test that the built tree can be changed and gives the same hash as an equivalent
+	// tree, whether hashed in parallel or held in a node database
+	tr, err := BuildFromSorted(sortedRecords(5000))
+	printErr(err)
+	parallelTr, err := BuildFromSorted(sortedRecords(5000), WithParallelHashing(4))
+	printErr(err)
+	dbTr, err := BuildFromSorted(sortedRecords(5000), WithNodeDB(NewMemNodeDB()), WithCacheSize(16))
+	printErr(err)
+
+	expdHash, _ := tr.GetHash()
+	if hash, _ := parallelTr.GetHash(); !bytes.Equal(hash, expdHash) {
+		t.Errorf("bad hash when hashed in parallel, expected %x found %x", expdHash, hash)
+	}
+	_, err = dbTr.Save()
+	printErr(err)
+	dbTr, err = LoadAVLTree(WithNodeDB(dbTr.config.db))
+	printErr(err)
+
+	for i := 0; i < 5000; i += 3 {
+		key := []byte(fmt.Sprintf("k%04d", i))
+		printErr(tr.Remove(key))
+		printErr(dbTr.Remove(key))
+	}
+	printErr(tr.Add([]byte("new"), []byte("value")))
+	printErr(dbTr.Add([]byte("new"), []byte("value")))
+
+	expdHash, _ = tr.GetHash()
+	recompute(&tr, tr.trunk)
+	if hash, _ := dbTr.GetHash(); !bytes.Equal(hash, expdHash) {
+		t.Errorf("bad hash of the node database tree, expected %x found %x", expdHash, hash)
+	}
+
+	//Test unsorted and repeated keys, and stopping the sequence once an error is found
+	badRecords := func(keys ...string) func(yield func(key, value []byte) bool) {
+		return func(yield func(key, value []byte) bool) {
+			for _, key := range keys {
+				if !yield([]byte(key), nil) {
+					return
+				}
+			}
+			t.Errorf("expected the sequence to be stopped")
+		}
+	}
+	if _, err = BuildFromSorted(badRecords("a", "c", "b", "d")); err != errUnsorted {
+		t.Errorf("expected an unsorted error, found %v", err)
+	}
+	if _, err = BuildFromSorted(badRecords("a", "b", "b", "c")); err != errDupVal {
+		t.Errorf("expected a duplicate value error, found %v", err)
+	}
+
+	//Test that a nil key is rejected, while an empty key is a valid key
+	nilKeyRecords := func(yield func(key, value []byte) bool) {
+		if yield(nil, []byte("value")) {
+			t.Errorf("expected the sequence to be stopped")
+		}
+	}
+	if _, err = BuildFromSorted(nilKeyRecords); err != errNilKey {
+		t.Errorf("expected a nil key error, found %v", err)
+	}
+	tr, err = BuildFromSorted(func(yield func(key, value []byte) bool) {
+		yield([]byte{}, []byte("value"))
+	})
+	printErr(err)
+	if value, err := tr.Get([]byte{}); err != nil || string(value) != "value" {
+		t.Errorf("bad value for the empty key, found %s %v", value, err)
+	}
+
+	//Test that the records are copied, so reusing the buffers of the sequence leaves the tree unchanged
+	buf := make([]byte, 5)
+	tr, err = BuildFromSorted(func(yield func(key, value []byte) bool) {
+		for i := 0; i < 100; i++ {
+			copy(buf, fmt.Sprintf("k%04d", i))
+			if !yield(buf, buf) {
+				return
+			}
+		}
+	})
+	printErr(err)
+	copy(buf, "xxxxx")
+	recompute(&tr, tr.trunk)
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("k%04d", i)
+		if value, err := tr.Get([]byte(key)); err != nil || string(value) != key {
+			t.Errorf("bad value for %v after reusing the buffers, found %s %v", key, value, err)
+		}
+	}
+}
+
+func BenchmarkBuildFromSorted(b *testing.B) {
+	keys := make([][]byte, 100000)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("k%08d", i))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := BuildFromSorted(func(yield func(key, value []byte) bool) {
+			for _, key := range keys {
+				if !yield(key, key) {
+					return
+				}
+			}
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAddSorted(b *testing.B) {
+	keys := make([][]byte, 100000)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("k%08d", i))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tr := NewAVLTree()
+		for _, key := range keys {
+			if err := tr.Add(key, key); err != nil {
+				b.Fatal(err)
+			}
+		}
+		tr.GetHash()
+	}
+}
@@ -0,0 +1,106 @@
+package AVL_Tree
+
+import (
+	"sync"
+)
+
+//Tree which may be used from many goroutines, wrapping an AVLTree with readers-writer
+// locking. Reads hold the read lock, so run concurrently with each other, while changes
+// hold the write lock. Reading a tree with a node database loads and unloads nodes,
+// so such trees hold the write lock for reads as well.
+type ConcurrentTree struct {
+	mtx  sync.RWMutex
+	tree AVLTree
+}
+
+//Create a new empty concurrent tree, options may be passed in to configure the tree
+func NewConcurrentTree(opts ...Option) *ConcurrentTree {
+	return &ConcurrentTree{
+		tree: NewAVLTree(opts...),
+	}
+}
+
+//Lock the tree for reading, returning the function to unlock it
+func (t *ConcurrentTree) readLock() (unlock func()) {
+	if t.tree.store != nil {
+		t.mtx.Lock()
+		return t.mtx.Unlock
+	}
+	t.mtx.RLock()
+	return t.mtx.RUnlock
+}
+
+//Lock the tree for reading once any changed nodes have been hashed, returning the function
+// to unlock it. As hashes are computed lazily, reading the hash of a changed tree hashes
+// its changed nodes, which is done holding the write lock.
+func (t *ConcurrentTree) hashedReadLock() (unlock func()) {
+	for {
+		unlock = t.readLock()
+		if !t.tree.trunk.dirty() {
+			return unlock
+		}
+		unlock()
+
+		t.mtx.Lock()
+		t.tree.trunk.rehash(&t.tree)
+		t.mtx.Unlock()
+	}
+}
+
+//Call the function with the tree locked for reading, allowing other reads such as
+// iterators, sequences, order queries, and proofs. The function must not change the tree.
+func (t *ConcurrentTree) View(fn func(tr *AVLTree)) {
+	defer t.hashedReadLock()()
+	fn(&t.tree)
+}
+
+//Call the function with the tree locked for changes, allowing functions such as saving the tree
+// or transactions, the error returned by the function is returned
+func (t *ConcurrentTree) Change(fn func(tr *AVLTree) error) error {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return fn(&t.tree)
+}
+
+/////////////////////////////
+// Tree Functions
+/////////////////////////////
+
+func (t *ConcurrentTree) GetHash() (hash []byte, err error) {
+	defer t.hashedReadLock()()
+	return t.tree.GetHash()
+}
+
+func (t *ConcurrentTree) Get(key []byte) (value []byte, err error) {
+	defer t.readLock()()
+	return t.tree.Get(key)
+}
+
+func (t *ConcurrentTree) Set(key, value []byte) error {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return t.tree.Set(key, value)
+}
+
+func (t *ConcurrentTree) Add(key, value []byte) error {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return t.tree.Add(key, value)
+}
+
+func (t *ConcurrentTree) Update(key, value []byte) error {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return t.tree.Update(key, value)
+}
+
+func (t *ConcurrentTree) Remove(key []byte) error {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return t.tree.Remove(key)
+}
+
+func (t *ConcurrentTree) TreeStructure() string {
+	defer t.readLock()()
+	return t.tree.TreeStructure()
+}
@@ -0,0 +1,118 @@
+package AVL_Tree
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+var _ Tree = (*ConcurrentTree)(nil)
+
+func TestConcurrentTree(t *testing.T) {
+
+	testConcurrent := func(name string, tr *ConcurrentTree) {
+
+		//Each goroutine changes its own keys, checking the values it reads back,
+		// while also reading shared keys changed by all goroutines
+		const goroutines, ops = 8, 2000
+		records := make([]map[string]string, goroutines)
+
+		var wg sync.WaitGroup
+		for g := 0; g < goroutines; g++ {
+			records[g] = make(map[string]string)
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+
+				rnd := rand.New(rand.NewSource(int64(g)))
+				for i := 0; i < ops; i++ {
+					key := fmt.Sprintf("g%d-k%02d", g, rnd.Intn(32))
+					value := fmt.Sprintf("v%d", i)
+					sharedKey := []byte(fmt.Sprintf("shared%02d", rnd.Intn(8)))
+
+					switch rnd.Intn(6) {
+					case 0:
+						if tr.Remove([]byte(key)) == nil {
+							delete(records[g], key)
+						}
+					case 1:
+						tr.Set(sharedKey, []byte(value))
+					case 2:
+						tr.Remove(sharedKey)
+					case 3:
+						tr.GetHash()
+						tr.Get(sharedKey)
+					case 4:
+						if rnd.Intn(20) == 0 {
+							tr.Change(func(tr *AVLTree) error {
+								_, err := tr.Save()
+								return err
+							})
+						}
+
+						//Records read within a view are in order
+						tr.View(func(tr *AVLTree) {
+							var prevKey []byte
+							for k := range tr.All() {
+								if prevKey != nil && bytes.Compare(prevKey, k) >= 0 {
+									t.Errorf("bad %v order, %s before %s", name, prevKey, k)
+								}
+								prevKey = k
+							}
+						})
+					default:
+						if err := tr.Set([]byte(key), []byte(value)); err != nil {
+							t.Errorf("bad %v set, %v", name, err)
+						}
+						records[g][key] = value
+					}
+
+					recievedVal, err := tr.Get([]byte(key))
+					expdValue, exists := records[g][key]
+					if string(recievedVal) != expdValue || (err == nil) != exists {
+						t.Errorf("bad %v value for %v, expected %v found %s %v", name, key, expdValue, recievedVal, err)
+						return
+					}
+				}
+			}(g)
+		}
+		wg.Wait()
+
+		//Test the final records and hash of the tree
+		size := 0
+		for g := range records {
+			for key, value := range records[g] {
+				recievedVal, err := tr.Get([]byte(key))
+				if err != nil || string(recievedVal) != value {
+					t.Errorf("bad %v value for %v, expected %v found %s %v", name, key, value, recievedVal, err)
+				}
+			}
+			size += len(records[g])
+		}
+		tr.View(func(avlTr *AVLTree) {
+			for i := 0; i < 8; i++ {
+				if _, err := avlTr.Get([]byte(fmt.Sprintf("shared%02d", i))); err == nil {
+					size++
+				}
+			}
+			if avlTr.Size() != size {
+				t.Errorf("bad %v size, expected %v found %v", name, size, avlTr.Size())
+			}
+
+			var buf bytes.Buffer
+			if _, err := avlTr.WriteTo(&buf); err != nil {
+				t.Fatal(err)
+			}
+
+			//Reading the tree verifies its hash against its records
+			if _, err := ReadTree(&buf); err != nil {
+				t.Errorf("bad %v tree, %v", name, err)
+			}
+		})
+	}
+
+	testConcurrent("in memory", NewConcurrentTree())
+	testConcurrent("node database", NewConcurrentTree(WithNodeDB(NewMemNodeDB()), WithCacheSize(16)))
+}
@@ -0,0 +1,149 @@
+package AVL_Tree
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/sha3"
+)
+
+//Hashers calculate the hash of the input generated for each node,
+// the same hasher must be used by a tree and the verification of its proofs
+type Hasher interface {
+	Sum(input []byte) []byte
+}
+
+//Adapter to use an ordinary function as a hasher
+type HasherFunc func(input []byte) []byte
+
+func (f HasherFunc) Sum(input []byte) []byte {
+	return f(input)
+}
+
+//SHA3-256 hasher, used by default
+type SHA3Hasher struct{}
+
+func (SHA3Hasher) Sum(input []byte) []byte {
+	hashBytes := sha3.Sum256(input)
+	return hashBytes[:]
+}
+
+//SHA-256 hasher
+type SHA256Hasher struct{}
+
+func (SHA256Hasher) Sum(input []byte) []byte {
+	hashBytes := sha256.Sum256(input)
+	return hashBytes[:]
+}
+
+//BLAKE2b-256 hasher
+type BLAKE2bHasher struct{}
+
+func (BLAKE2bHasher) Sum(input []byte) []byte {
+	hashBytes := blake2b.Sum256(input)
+	return hashBytes[:]
+}
+
+//Schemes for calculating the merkle hash of a node
+type HashScheme int
+
+const (
+	//Every node commits to its key, value, height, and both child hashes.
+	// Each field is length-prefixed and leaf and branch nodes use separate domain tags.
+	HashSchemeDomainSeparated HashScheme = iota
+
+	//Leaf nodes hash the concatenated record key and value,
+	// branch nodes hash the concatenated hash values of branches.
+	// Kept in order to reproduce the merkle hashes of existing trees,
+	// the records of branch nodes are not committed to by this scheme
+	// and no proofs can be made with it.
+	HashSchemeLegacy
+)
+
+//Domain tags prepended to the hash input of the domain separated scheme
+const (
+	leafTag   byte = 0x00
+	branchTag byte = 0x01
+)
+
+//Calculate the hash of a node from its record, height, and the hashes of its children,
+// a nil child hash represents a placeholder child. This is used both for
+// updating the hashes held within the tree and for verifying proofs.
+func (c *config) hashNode(key, value []byte, height int, leftHash, rightHash []byte) []byte {
+
+	var hashInput []byte = nil
+
+	switch c.scheme {
+	case HashSchemeLegacy:
+		hashInput = legacyHashInput(key, value, leftHash, rightHash)
+	default:
+		hashInput = domainSeparatedHashInput(key, value, height, leftHash, rightHash)
+	}
+
+	return c.hasher.Sum(hashInput)
+}
+
+//Does the hashing scheme commit to the records and positions of branch nodes?
+// Proofs can only be made with schemes that do, otherwise a branch node could
+// be presented as a leaf node holding the hashes of its children as its record.
+func (c *config) commitsBranches() bool {
+	return c.scheme != HashSchemeLegacy
+}
+
+//Hash input for the domain separated scheme
+func domainSeparatedHashInput(key, value []byte, height int, leftHash, rightHash []byte) []byte {
+
+	//Leaf nodes omit their placeholder children
+	if leftHash == nil && rightHash == nil {
+		return lengthPrefixed([]byte{leafTag}, uint64(height), key, value)
+	}
+
+	return lengthPrefixed([]byte{branchTag}, uint64(height), key, value, leftHash, rightHash)
+}
+
+//Hash input for the legacy scheme
+func legacyHashInput(key, value, leftHash, rightHash []byte) []byte {
+
+	//are either left or right children placeholders?
+	leftIsPH := leftHash == nil
+	rightIsPH := rightHash == nil
+
+	switch {
+	case !leftIsPH && !rightIsPH:
+		return concat(leftHash, rightHash)
+	case leftIsPH && !rightIsPH:
+		return rightHash
+	case !leftIsPH && rightIsPH:
+		return leftHash
+	}
+
+	return concat(key, value)
+}
+
+//Append the height and each length-prefixed field to the tag
+func lengthPrefixed(tag []byte, height uint64, fields ...[]byte) []byte {
+
+	out := binary.AppendUvarint(tag, height)
+	for _, field := range fields {
+		out = binary.AppendUvarint(out, uint64(len(field)))
+		out = append(out, field...)
+	}
+
+	return out
+}
+
+//Concatenate byte slices into a newly allocated slice,
+// the inputs are never written to
+func concat(slices ...[]byte) []byte {
+	length := 0
+	for _, s := range slices {
+		length += len(s)
+	}
+
+	out := make([]byte, 0, length)
+	for _, s := range slices {
+		out = append(out, s...)
+	}
+	return out
+}
@@ -0,0 +1,237 @@
+package AVL_Tree
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/sha3"
+)
+
+func TestHashScheme(t *testing.T) {
+
+	printErr := func(err error) {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	//Build a tree with the structure
+	//   b
+	//  / \
+	// a   c
+	buildTree := func(scheme HashScheme, valueB string) *AVLTree {
+		tr := NewAVLTree(WithHashScheme(scheme))
+		printErr(tr.Add([]byte("a"), []byte("vA")))
+		printErr(tr.Add([]byte("b"), []byte(valueB)))
+		printErr(tr.Add([]byte("c"), []byte("vC")))
+		return &tr
+	}
+
+	//Test that the legacy scheme reproduces existing merkle hashes
+	sum := func(input []byte) []byte {
+		hashBytes := sha3.Sum256(input)
+		return hashBytes[:]
+	}
+	expdHash := sum(append(sum([]byte("avA")), sum([]byte("cvC"))...))
+
+	legacyTr := buildTree(HashSchemeLegacy, "vB")
+	hash, err := legacyTr.GetHash()
+	printErr(err)
+	if !bytes.Equal(hash, expdHash) {
+		t.Errorf("bad legacy hash, expected %x found %x", expdHash, hash)
+	}
+
+	//Test that the branch record is only committed to by the domain separated scheme
+	legacyHash2, _ := buildTree(HashSchemeLegacy, "vBB").GetHash()
+	if !bytes.Equal(hash, legacyHash2) {
+		t.Errorf("expected legacy hash to ignore the branch record")
+	}
+
+	tr := buildTree(HashSchemeDomainSeparated, "vB")
+	hash1, _ := tr.GetHash()
+	hash2, _ := buildTree(HashSchemeDomainSeparated, "vBB").GetHash()
+	if bytes.Equal(hash1, hash2) {
+		t.Errorf("expected domain separated hash to commit to the branch record")
+	}
+
+	//Test that the boundary between key and value is committed to
+	legacy := newConfig([]Option{WithHashScheme(HashSchemeLegacy)})
+	if !bytes.Equal(legacy.hashNode([]byte("ab"), []byte("c"), 0, nil, nil),
+		legacy.hashNode([]byte("a"), []byte("bc"), 0, nil, nil)) {
+		t.Errorf("expected legacy hash to collide for records with the same concatenation")
+	}
+
+	domainSeparated := newConfig(nil)
+	if bytes.Equal(domainSeparated.hashNode([]byte("ab"), []byte("c"), 0, nil, nil),
+		domainSeparated.hashNode([]byte("a"), []byte("bc"), 0, nil, nil)) {
+		t.Errorf("expected domain separated hash to separate the key and value")
+	}
+
+	//Test that the position of a single child is committed to
+	childHash := domainSeparated.hashNode([]byte("a"), []byte("vA"), 0, nil, nil)
+	if bytes.Equal(domainSeparated.hashNode([]byte("b"), []byte("vB"), 1, childHash, nil),
+		domainSeparated.hashNode([]byte("b"), []byte("vB"), 1, nil, childHash)) {
+		t.Errorf("expected domain separated hash to commit to the position of children")
+	}
+}
+
+func TestHasher(t *testing.T) {
+
+	printErr := func(err error) {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	hashers := []Hasher{
+		SHA3Hasher{},
+		SHA256Hasher{},
+		BLAKE2bHasher{},
+		HasherFunc(func(input []byte) []byte {
+			hashBytes := sha3.Sum512(input)
+			return hashBytes[:]
+		}),
+	}
+
+	//Test that the default hasher is SHA3-256
+	defaultTr := NewAVLTree()
+	printErr(defaultTr.Add([]byte("a"), []byte("vA")))
+	defaultHash, _ := defaultTr.GetHash()
+
+	sha3Tr := NewAVLTree(WithHasher(SHA3Hasher{}))
+	printErr(sha3Tr.Add([]byte("a"), []byte("vA")))
+	sha3Hash, _ := sha3Tr.GetHash()
+
+	if !bytes.Equal(defaultHash, sha3Hash) {
+		t.Errorf("expected the default hasher to be SHA3-256")
+	}
+
+	//Test that each hasher is used by the tree and for proof verification
+	rootHashes := [][]byte{}
+	for i, hasher := range hashers {
+		tr := NewAVLTree(WithHasher(hasher))
+		for _, key := range []string{"a", "b", "c", "d", "e"} {
+			printErr(tr.Add([]byte(key), []byte("v"+key)))
+		}
+
+		rootHash, _ := tr.GetHash()
+		for _, otherHash := range rootHashes {
+			if bytes.Equal(rootHash, otherHash) {
+				t.Errorf("expected hasher %v to produce a unique root hash", i)
+			}
+		}
+		rootHashes = append(rootHashes, rootHash)
+
+		value, proof, err := tr.GetWithProof([]byte("d"))
+		printErr(err)
+		printErr(Verify(rootHash, []byte("d"), value, proof, WithHasher(hasher)))
+
+		//Verification must fail with a different hasher
+		other := hashers[(i+1)%len(hashers)]
+		if Verify(rootHash, []byte("d"), value, proof, WithHasher(other)) == nil {
+			t.Errorf("expected proof for hasher %v to fail with a different hasher", i)
+		}
+	}
+}
+
+func TestParallelHashing(t *testing.T) {
+
+	printErr := func(err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//Track the greatest number of concurrent hashes
+	var mtx sync.Mutex
+	active, maxActive := 0, 0
+	trackingHasher := HasherFunc(func(input []byte) []byte {
+		mtx.Lock()
+		active++
+		if active > maxActive {
+			maxActive = active
+		}
+		mtx.Unlock()
+
+		hashBytes := SHA3Hasher{}.Sum(input)
+
+		mtx.Lock()
+		active--
+		mtx.Unlock()
+		return hashBytes
+	})
+
+	//Trees hashed in parallel have the same hash as a serially hashed tree
+	serialTr := NewAVLTree()
+	trees := []AVLTree{
+		NewAVLTree(WithParallelHashing(4), WithHasher(trackingHasher)),
+		NewAVLTree(WithParallelHashing(16)),
+		NewAVLTree(WithParallelHashing(4), WithHashScheme(HashSchemeLegacy)),
+	}
+	legacyTr := NewAVLTree(WithHashScheme(HashSchemeLegacy))
+
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 10; i++ {
+		changes := 1 + rnd.Intn(10000)
+		for j := 0; j < changes; j++ {
+			key := []byte(fmt.Sprintf("k%05d", rnd.Intn(20000)))
+			value := []byte(fmt.Sprint(i))
+			remove := rnd.Intn(4) == 0
+
+			for _, tr := range append([]*AVLTree{&serialTr, &legacyTr}, &trees[0], &trees[1], &trees[2]) {
+				if remove {
+					tr.Remove(key)
+				} else {
+					printErr(tr.Set(key, value))
+				}
+			}
+		}
+
+		expdHash, _ := serialTr.GetHash()
+		expdLegacyHash, _ := legacyTr.GetHash()
+		for k := range trees {
+			hash, _ := trees[k].GetHash()
+			if k == 2 {
+				if !bytes.Equal(hash, expdLegacyHash) {
+					t.Fatalf("bad legacy hash after %v changes, expected %x found %x", changes, expdLegacyHash, hash)
+				}
+			} else if !bytes.Equal(hash, expdHash) {
+				t.Fatalf("bad hash for tree %v after %v changes, expected %x found %x", k, changes, expdHash, hash)
+			}
+		}
+	}
+
+	if maxActive > 4 {
+		t.Errorf("bad concurrent hash count, expected at most 4 found %v", maxActive)
+	}
+}
+
+func BenchmarkSerialHashing(b *testing.B) {
+	benchmarkHashing(b)
+}
+
+func BenchmarkParallelHashing(b *testing.B) {
+	benchmarkHashing(b, WithParallelHashing(runtime.GOMAXPROCS(0)))
+}
+
+//Benchmark hashing a tree of 100000 changed nodes
+func benchmarkHashing(b *testing.B, opts ...Option) {
+	tr := NewAVLTree(opts...)
+	for j := 0; j < 100000; j++ {
+		tr.Set([]byte(fmt.Sprintf("k%08d", j)), []byte("value"))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		for j := 0; j < 100000; j++ {
+			tr.Update([]byte(fmt.Sprintf("k%08d", j)), []byte(fmt.Sprint(i)))
+		}
+		b.StartTimer()
+		tr.GetHash()
+	}
+}
@@ -0,0 +1,186 @@
+package AVL_Tree
+
+import (
+	"bytes"
+)
+
+//Iterator over the key-value pairs of a tree in key order.
+// The tree must not be modified while iterating.
+type Iterator struct {
+	current   *node //nil once the iterator is exhausted
+	start     []byte
+	end       []byte
+	ascending bool
+	pinned    *nodeStore //store kept from unloading nodes until the iterator is exhausted or closed
+	err       error      //error which failed loading a node, the iterator is exhausted after this
+}
+
+//Create an iterator over the key-value pairs with keys in the range start (inclusive)
+// to end (exclusive). A nil start or end leaves the range unbounded.
+// If ascending is false the iterator begins with the greatest key.
+// If loading a node fails the iterator is not valid and Error returns the failure.
+func (t *AVLTree) Iterator(start, end []byte, ascending bool) (it *Iterator) {
+	it = &Iterator{
+		start:     start,
+		end:       end,
+		ascending: ascending,
+	}
+
+	if it.err = t.storeErr(); it.err != nil {
+		return
+	}
+	defer t.finishStore(&it.err)
+
+	if t.trunk.isPlaceholder() {
+		return
+	}
+
+	//Position the iterator on the first node within the range,
+	// a search for a missing key lands on a placeholder whose
+	// successor or predecessor is the first node within the range
+	var current *node
+	switch {
+	case ascending && start == nil:
+		current = t.trunk.findMin()
+	case ascending:
+		current = t.trunk.findNode(start)
+		if current.isPlaceholder() {
+			current = current.next()
+		}
+	case end == nil:
+		current = t.trunk.findMax()
+	default:
+		current = t.trunk.findNode(end).prev()
+	}
+
+	it.current = current
+	it.checkBounds()
+
+	//Unloading a node replaces the nodes of its subtree, which the iterator would
+	// no longer recognise when climbing back through them, so nothing is unloaded
+	// while the iterator remains valid
+	if it.current != nil && t.store != nil {
+		t.store.pins++
+		it.pinned = t.store
+	}
+
+	return it
+}
+
+//Is the iterator positioned on a key-value pair?
+func (it *Iterator) Valid() bool {
+	return it.current != nil
+}
+
+//Move the iterator to the next key-value pair. If loading the next
+// node fails the iterator is exhausted and Error returns the failure.
+func (it *Iterator) Next() {
+	if it.current == nil {
+		return
+	}
+
+	it.current, it.err = it.step()
+
+	it.checkBounds()
+	it.unpin()
+}
+
+//Step from the current node in the direction of the iterator,
+// a failed load is returned as the error
+func (it *Iterator) step() (next *node, err error) {
+	defer it.pinned.finishLoad(&err)
+
+	if it.ascending {
+		return it.current.next(), nil
+	}
+	return it.current.prev(), nil
+}
+
+//Returns the error which failed loading a node while iterating, if any.
+// The iterator is no longer valid once a load has failed.
+func (it *Iterator) Error() error {
+	return it.err
+}
+
+//Returns the key of the current key-value pair, nil if the iterator is not valid
+func (it *Iterator) Key() []byte {
+	if it.current == nil {
+		return nil
+	}
+	return it.current.key
+}
+
+//Returns the value of the current key-value pair, nil if the iterator is not valid
+func (it *Iterator) Value() []byte {
+	if it.current == nil {
+		return nil
+	}
+	return it.current.value
+}
+
+//Release the iterator, after which it is no longer valid
+func (it *Iterator) Close() {
+	it.current = nil
+	it.unpin()
+}
+
+//Allow the store to unload nodes again once the iterator is no longer valid
+func (it *Iterator) unpin() {
+	if it.current == nil && it.pinned != nil {
+		it.pinned.pins--
+		it.pinned = nil
+	}
+}
+
+//Exhaust the iterator if the current node has passed the far end of the range
+func (it *Iterator) checkBounds() {
+	if it.current == nil {
+		return
+	}
+
+	if it.ascending && it.end != nil && bytes.Compare(it.current.key, it.end) >= 0 {
+		it.current = nil
+	}
+
+	if !it.ascending && it.start != nil && bytes.Compare(it.current.key, it.start) < 0 {
+		it.current = nil
+	}
+}
+
+//Call fn for each key-value pair with keys beginning with the prefix in ascending key order,
+// the iteration stops early if fn returns false. The tree must not be modified by fn,
+// if it is, the iteration panics with errTreeModified. If loading a node fails
+// the iteration stops early and the failure is returned by Err.
+func (t *AVLTree) PrefixIterate(prefix []byte, fn func(k, v []byte) bool) {
+	if t.storeErr() != nil {
+		return
+	}
+	defer t.finishStore(new(error))
+
+	if t.trunk.isPlaceholder() {
+		return
+	}
+
+	//Seek to the first key with the prefix, which is either the prefix itself
+	// or the successor of the placeholder position of the prefix
+	current := t.trunk.findNode(prefix)
+	if current.isPlaceholder() {
+		current = current.next()
+	}
+
+	//Nodes are not unloaded while iterating, as for Iterator
+	if t.store != nil {
+		t.store.pins++
+		defer func() { t.store.pins-- }()
+	}
+
+	mutations := t.mutations
+	for ; current != nil && bytes.HasPrefix(current.key, prefix); current = current.next() {
+		if !fn(current.key, current.value) {
+			return
+		}
+		if t.mutations != mutations {
+			panic(errTreeModified)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package AVL_Tree
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestIterator(t *testing.T) {
+
+	//The AVLTree to be tested with
+	tr := NewAVLTree()
+
+	printErr := func(err error) {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	//Test iterating an empty tree
+	if tr.Iterator(nil, nil, true).Valid() {
+		t.Errorf("expected iterator over an empty tree to be invalid")
+	}
+
+	//Keys k00, k02 ... k38 so that there are gaps between every key
+	var allKeys []string
+	for i := 0; i < 40; i += 2 {
+		key := fmt.Sprintf("k%02d", i)
+		allKeys = append(allKeys, key)
+		printErr(tr.Add([]byte(key), []byte("v"+key)))
+	}
+
+	//Test iterating over a range against the expected keys,
+	// empty strings are used for unbounded range ends
+	iteratorTest := func(start, end string, ascending bool) {
+
+		var startKey, endKey []byte
+		if start != "" {
+			startKey = []byte(start)
+		}
+		if end != "" {
+			endKey = []byte(end)
+		}
+
+		var expdKeys []string
+		for _, key := range allKeys {
+			if (start == "" || key >= start) && (end == "" || key < end) {
+				expdKeys = append(expdKeys, key)
+			}
+		}
+		if !ascending {
+			for i, j := 0, len(expdKeys)-1; i < j; i, j = i+1, j-1 {
+				expdKeys[i], expdKeys[j] = expdKeys[j], expdKeys[i]
+			}
+		}
+
+		var keys []string
+		it := tr.Iterator(startKey, endKey, ascending)
+		for ; it.Valid(); it.Next() {
+			keys = append(keys, string(it.Key()))
+			if string(it.Value()) != "v"+string(it.Key()) {
+				t.Errorf("bad value for %s found %s", it.Key(), it.Value())
+			}
+		}
+		it.Close()
+
+		if strings.Join(keys, ",") != strings.Join(expdKeys, ",") {
+			t.Errorf("bad keys for range %v to %v ascending %v, expected %v found %v",
+				start, end, ascending, expdKeys, keys)
+		}
+	}
+
+	for _, ascending := range []bool{true, false} {
+		iteratorTest("", "", ascending)
+		iteratorTest("k10", "k20", ascending)
+		iteratorTest("k11", "k21", ascending)
+		iteratorTest("k10", "k11", ascending)
+		iteratorTest("k11", "k12", ascending)
+		iteratorTest("", "k10", ascending)
+		iteratorTest("k31", "", ascending)
+		iteratorTest("a", "b", ascending)
+		iteratorTest("z", "", ascending)
+		iteratorTest("", "a", ascending)
+		iteratorTest("k20", "k10", ascending)
+	}
+
+	//Test closing an iterator part way through
+	it := tr.Iterator(nil, nil, true)
+	it.Next()
+	it.Close()
+	if it.Valid() || it.Key() != nil || it.Value() != nil {
+		t.Errorf("expected closed iterator to be invalid")
+	}
+}
+
+func TestPrefixIterate(t *testing.T) {
+
+	//The AVLTree to be tested with
+	tr := NewAVLTree()
+
+	printErr := func(err error) {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	//Test iterating an empty tree
+	tr.PrefixIterate([]byte("a"), func(k, v []byte) bool {
+		t.Errorf("expected no keys for an empty tree")
+		return true
+	})
+
+	for _, key := range []string{"a", "a\xff", "a\xff\xff", "a\xff\xffb", "ab", "b", "b\x00", "user/1", "user/2", "user0", "\xff", "\xff\xff"} {
+		printErr(tr.Add([]byte(key), []byte("v"+key)))
+	}
+
+	//Test the keys found with a prefix against the expected keys,
+	// iteration is stopped after limit keys
+	prefixTest := func(prefix string, limit int, expdKeys []string) {
+		var keys []string
+		tr.PrefixIterate([]byte(prefix), func(k, v []byte) bool {
+			keys = append(keys, string(k))
+			if string(v) != "v"+string(k) {
+				t.Errorf("bad value for %q found %q", k, v)
+			}
+			return len(keys) < limit
+		})
+		if strings.Join(keys, ",") != strings.Join(expdKeys, ",") {
+			t.Errorf("bad keys for prefix %q, expected %q found %q", prefix, expdKeys, keys)
+		}
+	}
+
+	prefixTest("a", 10, []string{"a", "ab", "a\xff", "a\xff\xff", "a\xff\xffb"})
+	prefixTest("a\xff", 10, []string{"a\xff", "a\xff\xff", "a\xff\xffb"})
+	prefixTest("a\xff\xff", 10, []string{"a\xff\xff", "a\xff\xffb"})
+	prefixTest("b", 10, []string{"b", "b\x00"})
+	prefixTest("user/", 10, []string{"user/1", "user/2"})
+	prefixTest("\xff", 10, []string{"\xff", "\xff\xff"})
+	prefixTest("c", 10, nil)
+	prefixTest("aa", 10, nil)
+	prefixTest("a", 2, []string{"a", "ab"})
+	prefixTest("", 100, []string{"a", "ab", "a\xff", "a\xff\xff", "a\xff\xffb", "b", "b\x00", "user/1", "user/2", "user0", "\xff", "\xff\xff"})
+}
@@ -0,0 +1,134 @@
+package AVL_Tree
+
+import (
+	"bytes"
+	"sort"
+)
+
+//Proof of existence for many key-value pairs against a merkle root hash.
+// The proof holds the tree pruned down to the search paths of the keys
+// so that nodes shared between paths are only held once.
+type MultiProof struct {
+	Root *ProofNode
+}
+
+//Get the values for many existing keys along with a single proof
+// that all of the key-value pairs are held under the tree's merkle root hash
+func (t *AVLTree) GetMultiWithProof(keys [][]byte) (values [][]byte, proof *MultiProof, err error) {
+	if err = t.storeErr(); err != nil {
+		return
+	}
+	defer t.finishStore(&err)
+
+	if t.trunk.isPlaceholder() {
+		err = errEmptyTree
+		return
+	}
+
+	if !t.config.commitsBranches() {
+		err = errUnprovable
+		return
+	}
+
+	t.trunk.rehash(t)
+	for _, key := range keys {
+		matchNode := t.trunk.findNode(key)
+
+		if matchNode.isPlaceholder() {
+			err = errBadKey
+			return
+		}
+
+		values = append(values, matchNode.value)
+	}
+
+	//The keys are sorted so that they can be split between the branches of each node
+	sortedKeys := append([][]byte{}, keys...)
+	sort.Slice(sortedKeys, func(i, j int) bool {
+		return bytes.Compare(sortedKeys[i], sortedKeys[j]) < 0
+	})
+
+	proof = &MultiProof{
+		Root: t.trunk.multiProofNode(sortedKeys),
+	}
+
+	return
+}
+
+//Generate the multiproof node for the current node from the sorted keys held within its subtree.
+// Subtrees holding none of the keys are pruned.
+func (n *node) multiProofNode(sortedKeys [][]byte) *ProofNode {
+
+	if n.isPlaceholder() {
+		return nil
+	}
+
+	if len(sortedKeys) == 0 {
+		return &ProofNode{Hash: n.hash}
+	}
+
+	//Split the keys into those lesser than and greater than the current node key
+	lesser := sort.Search(len(sortedKeys), func(i int) bool {
+		return bytes.Compare(sortedKeys[i], n.key) >= 0
+	})
+	greater := lesser
+	for greater < len(sortedKeys) && bytes.Equal(sortedKeys[greater], n.key) {
+		greater++
+	}
+
+	return &ProofNode{
+		Key:    n.key,
+		Value:  n.value,
+		Height: n.height,
+		Left:   n.leftNode.multiProofNode(sortedKeys[:lesser]),
+		Right:  n.rightNode.multiProofNode(sortedKeys[greater:]),
+	}
+}
+
+//Verify that all of the key-value pairs are held under the merkle root hash
+// by searching for each key within the proof and recomputing the root hash.
+// Options must be passed in to match the hashing configuration of the tree
+// which generated the proof, records cannot be proven with the legacy scheme.
+func VerifyMulti(rootHash []byte, keys, values [][]byte, proof *MultiProof, opts ...Option) error {
+	c := newConfig(opts)
+
+	if !c.commitsBranches() {
+		return errUnprovable
+	}
+
+	if proof == nil || proof.Root == nil || len(keys) != len(values) {
+		return errBadProof
+	}
+
+	for i, key := range keys {
+		matchNode := proof.Root.search(key)
+
+		if matchNode == nil || matchNode.Key == nil || !bytes.Equal(matchNode.Value, values[i]) {
+			return errBadProof
+		}
+	}
+
+	if !bytes.Equal(proof.Root.computeHash(&c), rootHash) {
+		return errBadProof
+	}
+
+	return nil
+}
+
+//Search through the proof subtree for the node holding the key,
+// the search ends at a placeholder (nil) or pruned node if the key is not held
+func (pn *ProofNode) search(key []byte) *ProofNode {
+	for pn != nil && pn.Key != nil {
+
+		//Compare(a,b) will be 0 if a==b, -1 if a < b, and +1 if a > b
+		switch bytes.Compare(key, pn.Key) {
+		case 0:
+			return pn
+		case -1:
+			pn = pn.Left
+		case 1:
+			pn = pn.Right
+		}
+	}
+	return pn
+}
@@ -0,0 +1,147 @@
+package AVL_Tree
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestMultiProof(t *testing.T) {
+
+	//The AVLTree to be tested with
+	tr := NewAVLTree()
+
+	printErr := func(err error) {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	for i := 0; i < 64; i++ {
+		printErr(tr.Add([]byte(fmt.Sprintf("k%02d", i)), []byte(fmt.Sprintf("v%02d", i))))
+	}
+	rootHash, _ := tr.GetHash()
+
+	//Collect the keys of leaf nodes, which hold the provable records
+	var leafKeys [][]byte
+	var collectLeafs func(n *node)
+	collectLeafs = func(n *node) {
+		switch {
+		case n.isPlaceholder():
+		case n.leftNode.isPlaceholder() && n.rightNode.isPlaceholder():
+			leafKeys = append(leafKeys, n.key)
+		default:
+			collectLeafs(n.leftNode)
+			collectLeafs(n.rightNode)
+		}
+	}
+	collectLeafs(tr.trunk)
+
+	//Prove the leaf keys in reverse order to test the ordering of the proof
+	keys := [][]byte{}
+	for i := len(leafKeys) - 1; i >= 0; i-- {
+		keys = append(keys, leafKeys[i])
+	}
+
+	values, proof, err := tr.GetMultiWithProof(keys)
+	printErr(err)
+	printErr(VerifyMulti(rootHash, keys, values, proof))
+
+	for i, key := range keys {
+		expdVal, _ := tr.Get(key)
+		if string(values[i]) != string(expdVal) {
+			t.Errorf("bad value for %s, expected %s found %s", key, expdVal, values[i])
+		}
+	}
+
+	//Test that shared nodes are only held once within the proof,
+	// every node in the tree is held as all leafs are proven
+	var countNodes func(pn *ProofNode) int
+	countNodes = func(pn *ProofNode) int {
+		if pn == nil {
+			return 0
+		}
+		return 1 + countNodes(pn.Left) + countNodes(pn.Right)
+	}
+	if count := countNodes(proof.Root); count != 64 {
+		t.Errorf("bad number of nodes held in the proof, expected 64 found %v", count)
+	}
+
+	//Test that a subset of keys prunes the rest of the tree
+	values, proof, err = tr.GetMultiWithProof(keys[:2])
+	printErr(err)
+	printErr(VerifyMulti(rootHash, keys[:2], values, proof))
+	if VerifyMulti(rootHash, keys[:3], append(values, []byte("v")), proof) == nil {
+		t.Errorf("expected multiproof to fail for a key not held within the proof")
+	}
+
+	//Test that tampered values fail verification
+	values[1] = []byte("bad")
+	if VerifyMulti(rootHash, keys[:2], values, proof) == nil {
+		t.Errorf("expected multiproof to fail with a bad value")
+	}
+
+	//Test retrieving proofs for missing and branch keys
+	_, _, err = tr.GetMultiWithProof([][]byte{keys[0], []byte("z")})
+	if err != errBadKey {
+		t.Errorf("expected a bad key error, found %v", err)
+	}
+	branchKeys := [][]byte{keys[0], tr.trunk.key}
+	values, proof, err = tr.GetMultiWithProof(branchKeys)
+	printErr(err)
+	printErr(VerifyMulti(rootHash, branchKeys, values, proof))
+}
+
+func TestForgedMultiProof(t *testing.T) {
+
+	//The AVLTree to be tested with
+	//      d
+	//    /   \
+	//   b     f
+	//  / \   / \
+	// a   c e   g
+	tr := NewAVLTree()
+	for _, key := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		if err := tr.Add([]byte(key), []byte("v"+key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rootHash, _ := tr.GetHash()
+
+	//A branch node must not be provable as a leaf node holding the hashes
+	// of its children as its key and value
+	for _, branchKey := range []string{"b", "d", "f"} {
+		keys, values, proof := forgeMultiProof(&tr, branchKey)
+		if VerifyMulti(rootHash, keys, values, proof) == nil {
+			t.Errorf("expected forged multiproof for %v to be rejected", branchKey)
+		}
+	}
+}
+
+//Forge a multiproof which presents a branch node as a leaf node with the
+// hashes of its children held as the key and value. The keys of the parents
+// are replaced so that a search for the forged key leads to the forged node.
+// The proof is built directly from the tree as the legacy scheme refuses proofs.
+func forgeMultiProof(tr *AVLTree, branchKey string) (keys, values [][]byte, proof *MultiProof) {
+	branch := tr.trunk.findNode([]byte(branchKey))
+	key := branch.leftNode.hash
+	value := branch.rightNode.hash
+
+	proof = &MultiProof{
+		Root: tr.trunk.multiProofNode([][]byte{[]byte(branchKey)}),
+	}
+
+	pn := proof.Root
+	for !bytes.Equal(pn.Key, []byte(branchKey)) {
+		if bytes.Compare([]byte(branchKey), pn.Key) < 0 {
+			pn.Key = append(append([]byte{}, key...), 0x00)
+			pn = pn.Left
+		} else {
+			pn.Key = key[:len(key)-1]
+			pn = pn.Right
+		}
+	}
+	*pn = ProofNode{Key: key, Value: value}
+
+	return [][]byte{key}, [][]byte{value}, proof
+}
@@ -0,0 +1,86 @@
+package AVL_Tree
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+//Tree with lock-free readers, using multiversion concurrency control. Writers build the
+// changed tree as a new immutable persistent tree, sharing the unchanged nodes, and publish
+// it with an atomic pointer swap. Reads use the latest published tree without taking a lock,
+// and always see a consistent tree, while writers take turns changing the tree.
+type MVCCTree struct {
+	mtx     sync.Mutex //held by the writer
+	current atomic.Pointer[PersistentTree]
+}
+
+//Create a new empty MVCC tree, options may be passed in to configure the tree
+func NewMVCCTree(opts ...Option) *MVCCTree {
+	t := new(MVCCTree)
+	tree := NewPersistentTree(opts...)
+	t.current.Store(&tree)
+	return t
+}
+
+//Returns a reader handle holding the latest published tree. The handle is never affected
+// by later changes to the tree, so its functions and sequences see a consistent tree and
+// take no lock. Changes made through the handle only change the handle.
+func (t *MVCCTree) Snapshot() PersistentTree {
+	return *t.current.Load()
+}
+
+//Call the function with a copy of the latest tree as the writer, publishing the changed tree
+// once it returns. If the function returns an error none of its changes are published and the
+// error is returned. Readers only see the changes once they are all published.
+func (t *MVCCTree) Change(fn func(tr *PersistentTree) error) error {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	tree := *t.current.Load()
+	if err := fn(&tree); err != nil {
+		return err
+	}
+	t.current.Store(&tree)
+
+	return nil
+}
+
+/////////////////////////////
+// Tree Functions
+/////////////////////////////
+
+func (t *MVCCTree) GetHash() (hash []byte, err error) {
+	return t.current.Load().GetHash()
+}
+
+func (t *MVCCTree) Get(key []byte) (value []byte, err error) {
+	return t.current.Load().Get(key)
+}
+
+func (t *MVCCTree) Set(key, value []byte) error {
+	return t.Change(func(tr *PersistentTree) error {
+		return tr.Set(key, value)
+	})
+}
+
+func (t *MVCCTree) Add(key, value []byte) error {
+	return t.Change(func(tr *PersistentTree) error {
+		return tr.Add(key, value)
+	})
+}
+
+func (t *MVCCTree) Update(key, value []byte) error {
+	return t.Change(func(tr *PersistentTree) error {
+		return tr.Update(key, value)
+	})
+}
+
+func (t *MVCCTree) Remove(key []byte) error {
+	return t.Change(func(tr *PersistentTree) error {
+		return tr.Remove(key)
+	})
+}
+
+func (t *MVCCTree) TreeStructure() string {
+	return t.current.Load().TreeStructure()
+}
@@ -0,0 +1,107 @@
+package AVL_Tree
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+var _ Tree = (*MVCCTree)(nil)
+
+func TestMVCCTree(t *testing.T) {
+
+	tr := NewMVCCTree()
+
+	printErr := func(err error) {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	//The writer adds a key and sets the count of keys in a single change,
+	// so every published tree holds a count matching its keys
+	const changes = 2000
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < changes; i++ {
+			printErr(tr.Change(func(tr *PersistentTree) error {
+				if err := tr.Add([]byte(fmt.Sprintf("k%04d", i)), []byte("v")); err != nil {
+					return err
+				}
+				return tr.Set([]byte("count"), []byte(fmt.Sprint(i+1)))
+			}))
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				snapshot := tr.Snapshot()
+				count, err := snapshot.Get([]byte("count"))
+				if err != nil {
+					continue
+				}
+
+				//The sequence holds exactly the keys counted
+				keys := 0
+				for key := range snapshot.Range([]byte("k"), nil) {
+					if string(key) != fmt.Sprintf("k%04d", keys) {
+						t.Errorf("bad key %s, expected k%04d", key, keys)
+						return
+					}
+					keys++
+				}
+				if fmt.Sprint(keys) != string(count) || snapshot.Size() != keys+1 {
+					t.Errorf("inconsistent snapshot, count %s with %v keys and size %v", count, keys, snapshot.Size())
+					return
+				}
+
+				hash, _ := snapshot.GetHash()
+				if hash2, _ := snapshot.GetHash(); !bytes.Equal(hash, hash2) {
+					t.Errorf("snapshot hash changed")
+				}
+				tr.Get([]byte("k0000"))
+				tr.GetHash()
+			}
+		}()
+	}
+	<-done
+	wg.Wait()
+
+	//A failed change publishes none of its changes
+	hash, _ := tr.GetHash()
+	err := tr.Change(func(tr *PersistentTree) error {
+		printErr(tr.Add([]byte("new"), []byte("v")))
+		return tr.Add([]byte("k0000"), []byte("v"))
+	})
+	if err != errDupVal {
+		t.Errorf("expected a duplicate value error, found %v", err)
+	}
+	if _, err = tr.Get([]byte("new")); err != errBadKey {
+		t.Errorf("expected a bad key error after the failed change, found %v", err)
+	}
+	if hash2, _ := tr.GetHash(); !bytes.Equal(hash, hash2) {
+		t.Errorf("bad hash after the failed change, expected %x found %x", hash, hash2)
+	}
+
+	//The tree produces the same hash as an AVLTree with the same changes
+	avlTr := NewAVLTree()
+	for i := 0; i < changes; i++ {
+		avlTr.Add([]byte(fmt.Sprintf("k%04d", i)), []byte("v"))
+		avlTr.Set([]byte("count"), []byte(fmt.Sprint(i+1)))
+	}
+	if expdHash, _ := avlTr.GetHash(); !bytes.Equal(hash, expdHash) {
+		t.Errorf("bad hash, expected %x found %x", expdHash, hash)
+	}
+}
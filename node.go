@@ -2,25 +2,29 @@ package AVL_Tree
 
 import (
 	"bytes"
-
-	"golang.org/x/crypto/sha3"
+	"sync"
 )
 
 type node struct {
 	key       []byte
 	value     []byte
 	height    int
+	size      int //number of real nodes within the subtree, including this node
 	hash      []byte
-	parNode   *node //Parent AVL node
-	leftNode  *node //Left node with key less than current node
-	rightNode *node //Right node with key greater than current node
+	parNode   *node      //Parent AVL node
+	leftNode  *node      //Left node with key less than current node
+	rightNode *node      //Right node with key greater than current node
+	id        uint64     //ID of the node's record in the node database, 0 if unsaved
+	store     *nodeStore //Store to load the node from, only set while the node is unloaded
 }
 
 //Generate a new leaf node with parent and hash
 // note that the parents child node does not get
 // assigned within this function and must be assigned
 // wherever this function is called
+// The tree (tr) must be passed in to hash with the tree's hashing scheme.
 func newNodeLeaf(
+	tr *AVLTree,
 	parNode *node,
 	key,
 	value []byte) *node {
@@ -29,6 +33,7 @@ func newNodeLeaf(
 		key:       key,
 		value:     value,
 		height:    0,
+		size:      1,
 		hash:      nil,
 		parNode:   parNode,
 		leftNode:  nil,
@@ -39,7 +44,7 @@ func newNodeLeaf(
 	out.leftNode = newNodePlaceholder(out)
 	out.rightNode = newNodePlaceholder(out)
 
-	out.updateHash()
+	out.updateHash(tr)
 
 	return out
 }
@@ -55,6 +60,7 @@ func newNodePlaceholder(
 		key:       nil,
 		value:     nil,
 		height:    0,
+		size:      0,
 		hash:      nil,
 		parNode:   parNode,
 		leftNode:  nil,
@@ -68,7 +74,12 @@ func newNodePlaceholder(
 // Attribute Functions
 /////////////////////////////
 
+//Unloaded nodes are loaded from the node database when first checked,
+// all node functions check a node with this before accessing its children
 func (n *node) isPlaceholder() bool {
+	if n.store != nil {
+		n.load()
+	}
 	if n.key == nil {
 		return true
 	}
@@ -177,43 +188,126 @@ func (n *node) findMax() *node {
 	return n.rightNode.findMax()
 }
 
+//Return the in-order successor of the node, or nil if it holds the maximum key.
+// If called on a placeholder, the successor of its position is returned.
+func (n *node) next() *node {
+	if !n.isPlaceholder() && !n.rightNode.isPlaceholder() {
+		return n.rightNode.findMin()
+	}
+
+	//Climb until arriving from a left child
+	for !n.isTrunk() && !n.isLeftChild() {
+		n = n.parNode
+	}
+	return n.parNode
+}
+
+//Return the in-order predecessor of the node, or nil if it holds the minimum key.
+// If called on a placeholder, the predecessor of its position is returned.
+func (n *node) prev() *node {
+	if !n.isPlaceholder() && !n.leftNode.isPlaceholder() {
+		return n.leftNode.findMax()
+	}
+
+	//Climb until arriving from a right child
+	for !n.isTrunk() && n.isLeftChild() {
+		n = n.parNode
+	}
+	return n.parNode
+}
+
 /////////////////////////////
 // Write Functions
 /////////////////////////////
 
-func (n *node) updateHeightAndHash() {
+//Update the height, subtree size, and hash of the current node.
+func (n *node) updateHeightAndHash(tr *AVLTree) {
+	n.record(tr)
 	n.updateHeight()
-	n.updateHash()
+	n.updateSize()
+	n.updateHash(tr)
 }
 
-//Update the hash value stored in a node.
-// For branch nodes hash the concatenated hash values of branches.
-// For leaf nodes hash the concatenated record key and value.
-func (n *node) updateHash() {
+//Update the hash value stored in a node using the tree's hashing scheme.
+// The tree (tr) must be passed in in order to retrieve the hashing scheme.
+func (n *node) updateHash(tr *AVLTree) {
 
 	if n.isPlaceholder() {
 		return
 	}
 
-	var hashInput []byte = nil
+	n.markUnsaved(tr)
 
-	//are either left or right children placeholders?
-	leftIsPH := n.leftNode.isPlaceholder()
-	rightIsPH := n.rightNode.isPlaceholder()
+	//Hashes are computed lazily, a nil hash marks the node to be hashed by rehash.
+	// As all changes update the nodes above the changed node, all nodes above it are marked as well.
+	n.hash = nil
+}
 
-	switch {
-	case !leftIsPH && !rightIsPH:
-		hashInput = append(n.leftNode.hash, n.rightNode.hash...)
-	case leftIsPH && !rightIsPH:
-		hashInput = n.rightNode.hash
-	case !leftIsPH && rightIsPH:
-		hashInput = n.leftNode.hash
-	case leftIsPH && rightIsPH:
-		hashInput = append(n.key, n.value...)
+//Returns true if the node is marked to be hashed,
+// placeholders and unloaded nodes are never marked
+func (n *node) dirty() bool {
+	return n.hash == nil && n.key != nil
+}
+
+//Recursively hash the nodes of the subtree marked by updateHash, each marked node is
+// hashed once after its children. This must be called on the trunk before reading hashes.
+func (n *node) rehash(tr *AVLTree) {
+	var workers chan struct{}
+	if tr.config.hashWorkers > 1 {
+		workers = make(chan struct{}, tr.config.hashWorkers-1)
+	}
+	n.rehashSubtree(tr, workers)
+}
+
+//Minimum number of nodes in a subtree for hashing to be split between goroutines,
+// smaller subtrees are hashed serially as they do not outweigh the cost of a goroutine
+const parallelHashMin = 1024
+
+//Hash the marked nodes of the subtree. Subtrees of at least parallelHashMin nodes hash their
+// left subtree in a new goroutine while a worker is free, otherwise hashing continues serially,
+// so no more goroutines than the workers' capacity are added. Without workers (nil) hashing is serial.
+func (n *node) rehashSubtree(tr *AVLTree, workers chan struct{}) {
+	if !n.dirty() {
+		return
+	}
+
+	parallel := false
+	if n.size >= parallelHashMin {
+		select {
+		case workers <- struct{}{}:
+			parallel = true
+		default:
+		}
+	}
+
+	if parallel {
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n.leftNode.rehashSubtree(tr, workers)
+			<-workers
+		}()
+		n.rightNode.rehashSubtree(tr, workers)
+		wg.Wait()
+	} else {
+		n.leftNode.rehashSubtree(tr, workers)
+		n.rightNode.rehashSubtree(tr, workers)
+	}
+
+	n.hash = tr.config.hashNode(n.key, n.value, n.height, n.leftNode.hash, n.rightNode.hash)
+}
+
+//Mark a saved node as changed, orphaning its saved record.
+// The tree (tr) must be passed in in order to retrieve the node store.
+func (n *node) markUnsaved(tr *AVLTree) {
+	if n.id == 0 {
+		return
 	}
 
-	hashBytes := sha3.Sum256(hashInput)
-	n.hash = hashBytes[:]
+	n.record(tr)
+	tr.store.orphan(n)
+	n.id = 0
 }
 
 //Update the height of the current node.
@@ -234,6 +328,16 @@ func (n *node) updateHeight() {
 	return
 }
 
+//Update the subtree size of the current node.
+func (n *node) updateSize() {
+
+	if n.isPlaceholder() {
+		return
+	}
+
+	n.size = n.leftNode.size + n.rightNode.size + 1
+}
+
 //Update balance for current node.
 // The tree (tr) must be passed in in order to update the trunk node value if it changes.
 func (n *node) updateBalance(tr *AVLTree) {
@@ -241,14 +345,14 @@ func (n *node) updateBalance(tr *AVLTree) {
 
 	switch {
 	case bal > 1:
-		if n.rightNode.getBalance() > 0 { //Left Left Rotation
+		if n.rightNode.getBalance() >= 0 { //Left Left Rotation (balanced children only occur after removals)
 			n.rotate(tr, true) //rotateLeft
 		} else { //Right Left Rotation
 			n.rightNode.rotate(tr, false) //rotateRight
 			n.rotate(tr, true)
 		}
 	case bal < -1:
-		if n.leftNode.getBalance() < 0 { //Right Right Rotation
+		if n.leftNode.getBalance() <= 0 { //Right Right Rotation
 			n.rotate(tr, false)
 		} else { //Left Right Rotation
 			n.leftNode.rotate(tr, true)
@@ -267,7 +371,7 @@ func (n *node) updateHeightBalanceRecursive(tr *AVLTree) {
 		return
 	}
 
-	n.updateHeightAndHash()
+	n.updateHeightAndHash(tr)
 	n.updateBalance(tr)
 
 	if !n.isTrunk() {
@@ -287,14 +391,30 @@ func (n *node) rotate(tr *AVLTree, leftRotation bool) {
 	//node moving up
 	var nodeUp *node
 
+	//Record all nodes which are changed, the node moving down, the node moving up,
+	// its child moving across, and the parent of the node moving down
+	n.record(tr)
+	if !n.isTrunk() {
+		n.parNode.record(tr)
+	}
+	if leftRotation {
+		n.rightNode.record(tr)
+		n.rightNode.leftNode.record(tr)
+	} else {
+		n.leftNode.record(tr)
+		n.leftNode.rightNode.record(tr)
+	}
+
 	//Old parent takes owernership of left nodes right child as its left child
 	if leftRotation {
 		nodeUp = n.rightNode
 		n.rightNode = nodeUp.leftNode
+		n.rightNode.parNode = n
 		nodeUp.leftNode = n
 	} else {
 		nodeUp = n.leftNode
 		n.leftNode = nodeUp.rightNode
+		n.leftNode.parNode = n
 		nodeUp.rightNode = n
 	}
 
@@ -316,8 +436,8 @@ func (n *node) rotate(tr *AVLTree, leftRotation bool) {
 	}
 
 	//Update effected heights
-	n.updateHeightAndHash()
-	nodeUp.updateHeightAndHash()
+	n.updateHeightAndHash(tr)
+	nodeUp.updateHeightAndHash(tr)
 
 	return
 }
@@ -326,25 +446,33 @@ func (n *node) rotate(tr *AVLTree, leftRotation bool) {
 // Note this function does not actually perform a rebalance
 //  as it iteratively calls itself when a rebalance does not
 //  need to occure. Rebalance should be perfomed after an
-//  external remove call.
-func (n *node) remove() {
+//  external remove call, starting from the returned node which
+//  is the lowest node effected by the removal.
+// The tree (tr) must be passed in in order to update the trunk node value if it changes.
+func (n *node) remove(tr *AVLTree) (updateFrom *node) {
 
 	//Never remove a placeholder,
 	// this should be verified before calling this function
 	if n.isPlaceholder() {
-		return
+		return n
 	}
 
 	//Replace the matchNode position held under the parents node
-	// to the input setTo
+	// (or the tree's trunk) to the input setTo
 	setParentsChild := func(setTo *node) {
+		setTo.record(tr)
 		if !n.isTrunk() {
-
-			if n.isLeftChild() {
-				n.parNode.leftNode = setTo
-			} else {
-				n.parNode.rightNode = setTo
-			}
+			n.parNode.record(tr)
+		}
+		setTo.parNode = n.parNode
+
+		switch {
+		case n.isTrunk():
+			tr.trunk = setTo
+		case n.isLeftChild():
+			n.parNode.leftNode = setTo
+		default:
+			n.parNode.rightNode = setTo
 		}
 	}
 
@@ -354,22 +482,16 @@ func (n *node) remove() {
 
 	switch {
 
-	//If leaf node being deleted, just remove reference to it TODO verify if the memory can be released here?
+	//If leaf node being deleted, just remove reference to it
 	case leftIsPH && rightIsPH:
 		setParentsChild(newNodePlaceholder(n.parNode))
-		//matchNode = nil
-		return
 
 	//If there is only one branch off of node to delete
 	//  then replace node with one branch node
 	case leftIsPH && !rightIsPH:
 		setParentsChild(n.rightNode)
-		n.rightNode.parNode = n.parNode
-		return
 	case !leftIsPH && rightIsPH:
 		setParentsChild(n.leftNode)
-		n.leftNode.parNode = n.parNode
-		return
 
 	//If there are two branches off of node to delete
 	//  determine the longest sub branch and on that branch
@@ -384,22 +506,32 @@ func (n *node) remove() {
 		//Determine the direction to replace, and node to switch from
 		var replaceFromNode *node
 		if n.getBalance() >= 0 {
-			replaceFromNode = n.findMin()
+			replaceFromNode = n.rightNode.findMin()
 		} else {
-			replaceFromNode = n.findMax()
+			replaceFromNode = n.leftNode.findMax()
 		}
 
-		//Temporarily save the replacement key and value, delete its original position
+		//Temporarily save the replacement key and value, delete its original position.
+		// The replacement node never has two branches so this is not recursive any further,
+		// the updates must start from below the replacement nodes original position.
 		replaceFromKey := replaceFromNode.key
 		replaceFromValue := replaceFromNode.value
-		replaceFromNode.remove()
+		updateFrom = replaceFromNode.remove(tr)
 
 		//Now replace the key and value for the target node to delete
 		// the branches of this node to stay the same
+		n.record(tr)
 		n.key = replaceFromKey
 		n.value = replaceFromValue
 		return
 	}
 
-	return
+	n.markUnsaved(tr)
+
+	//The parent of the removed node is the lowest effected node,
+	// if the trunk was removed its replacement is used instead
+	if n.isTrunk() {
+		return tr.trunk
+	}
+	return n.parNode
 }
@@ -13,9 +13,9 @@ func TestNode(t *testing.T) {
 	var a, b, c *node
 
 	setNewLeafs := func() {
-		a = newNodeLeaf(nil, []byte("a"), []byte("vA"))
-		b = newNodeLeaf(nil, []byte("b"), []byte("vB"))
-		c = newNodeLeaf(nil, []byte("c"), []byte("vC"))
+		a = newNodeLeaf(&tr, nil, []byte("a"), []byte("vA"))
+		b = newNodeLeaf(&tr, nil, []byte("b"), []byte("vB"))
+		c = newNodeLeaf(&tr, nil, []byte("c"), []byte("vC"))
 	}
 
 	//String the three nodes together to a basic unbalanced tree
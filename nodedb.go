@@ -0,0 +1,444 @@
+package AVL_Tree
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+)
+
+//Database of encoded node records stored by ID, which a tree created with
+// WithNodeDB saves its nodes to and loads them from on demand.
+// Get must return nil data without an error if no record is stored under the ID,
+// and all functions must be safe to call from multiple goroutines.
+type NodeDB interface {
+	Get(id uint64) (data []byte, err error)
+	Put(id uint64, data []byte) error
+	Delete(id uint64) error
+}
+
+//Node databases may also implement Sync, which is called after
+// the tree is saved to make the saved records durable
+type syncer interface {
+	Sync() error
+}
+
+/////////////////////////////
+// Memory Database
+/////////////////////////////
+
+//Node database held in memory
+type MemNodeDB struct {
+	mtx     sync.RWMutex
+	records map[uint64][]byte
+}
+
+//Create a new empty memory node database
+func NewMemNodeDB() *MemNodeDB {
+	return &MemNodeDB{
+		records: make(map[uint64][]byte),
+	}
+}
+
+func (db *MemNodeDB) Get(id uint64) (data []byte, err error) {
+	db.mtx.RLock()
+	defer db.mtx.RUnlock()
+
+	if record, ok := db.records[id]; ok {
+		data = append([]byte{}, record...)
+	}
+	return
+}
+
+func (db *MemNodeDB) Put(id uint64, data []byte) error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+
+	db.records[id] = append([]byte{}, data...)
+	return nil
+}
+
+func (db *MemNodeDB) Delete(id uint64) error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+
+	delete(db.records, id)
+	return nil
+}
+
+//Returns the number of records held
+func (db *MemNodeDB) Len() int {
+	db.mtx.RLock()
+	defer db.mtx.RUnlock()
+
+	return len(db.records)
+}
+
+/////////////////////////////
+// File Database
+/////////////////////////////
+
+//errors used for node database files
+var errBadNodeFile error = errors.New("Node database file format is not supported")
+var errCorruptNodeFile error = errors.New("Node database file is corrupt")
+var errClosedNodeFile error = errors.New("Node database file is closed")
+
+//used internally to signal an operation torn by an interrupted write
+var errTornNodeFile error = errors.New("Node database file operation is torn")
+
+//Node database files begin with the magic bytes followed by the format version.
+//
+// Version 1 is an append-only log of operations, each holding:
+//  - an operation byte which is 1 to put a record or 0 to delete it
+//  - the uvarint ID and the uvarint length-prefixed record (empty for deletions)
+//  - the big-endian CRC-32 (Castagnoli) checksum of the preceding bytes of the operation
+//
+// The log is replayed when the file is opened to index the latest record of each ID.
+// A torn operation at the end of the file, from a write interrupted by a crash, is truncated.
+// Once operations which no longer put an indexed record take up most of the file, the file
+// is compacted by writing the indexed records to a new file which replaces it.
+var nodeFileMagic = []byte("AVLN")
+
+const (
+	nodeFileVersion byte = 1
+
+	nodeFileDelete byte = 0
+	nodeFilePut    byte = 1
+
+	//Files are only compacted once this many bytes can be reclaimed
+	nodeFileCompactMin = 1 << 20
+)
+
+//Node database held in a single file, with an index of the records held in memory
+type FileNodeDB struct {
+	mtx   sync.RWMutex
+	path  string
+	file  *os.File
+	size  int64 //offset at which the next operation is appended
+	live  int64 //length of the operations putting the indexed records
+	index map[uint64]fileRecord
+}
+
+//Position of a record's bytes within the file
+type fileRecord struct {
+	offset int64
+	length int
+}
+
+//Open the node database file at the path, creating it if it doesn't exist
+func OpenFileNodeDB(path string) (*FileNodeDB, error) {
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	db := &FileNodeDB{
+		path:  path,
+		file:  file,
+		index: make(map[uint64]fileRecord),
+	}
+
+	if err = db.replay(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+//Replay the log of operations to build the index
+func (db *FileNodeDB) replay() error {
+
+	info, err := db.file.Stat()
+	if err != nil {
+		return err
+	}
+	fileSize := info.Size()
+
+	header := append(append([]byte{}, nodeFileMagic...), nodeFileVersion)
+
+	//Write the header to a new file
+	if fileSize == 0 {
+		if _, err = db.file.WriteAt(header, 0); err != nil {
+			return err
+		}
+		db.size = int64(len(header))
+		return nil
+	}
+
+	r := bufio.NewReader(io.NewSectionReader(db.file, 0, fileSize))
+
+	readHeader := make([]byte, len(header))
+	if _, err = io.ReadFull(r, readHeader); err != nil || !bytes.Equal(readHeader, header) {
+		return errBadNodeFile
+	}
+	db.size = int64(len(header))
+
+	for db.size < fileSize {
+		op, id, data, n, err := readFileOp(r, fileSize-db.size)
+		switch {
+		case err == errTornNodeFile:
+			return db.file.Truncate(db.size)
+		case err != nil:
+			return err
+		}
+
+		switch op {
+		case nodeFilePut:
+			db.setRecord(id, fileRecord{
+				offset: db.size + n - 4 - int64(len(data)),
+				length: len(data),
+			})
+		case nodeFileDelete:
+			db.deleteRecord(id)
+		}
+		db.size += n
+	}
+
+	return nil
+}
+
+//Read an operation from the remaining bytes of the file, returning its length n.
+// An operation which does not fit in the remaining bytes or does not match its
+// checksum while ending the file is torn, otherwise the file is corrupt.
+func readFileOp(r *bufio.Reader, remaining int64) (op byte, id uint64, data []byte, n int64, err error) {
+
+	length := uint64(0)
+	op, err = r.ReadByte()
+	if err == nil {
+		id, err = binary.ReadUvarint(r)
+	}
+	if err == nil {
+		length, err = binary.ReadUvarint(r)
+	}
+	if err != nil {
+		return op, id, nil, 0, errTornNodeFile
+	}
+
+	head := []byte{op}
+	head = binary.AppendUvarint(head, id)
+	head = binary.AppendUvarint(head, length)
+	if length > uint64(remaining) || int64(len(head))+int64(length)+4 > remaining {
+		return op, id, nil, 0, errTornNodeFile
+	}
+	n = int64(len(head)) + int64(length) + 4
+
+	body := make([]byte, length+4)
+	if _, err = io.ReadFull(r, body); err != nil {
+		return op, id, nil, 0, errTornNodeFile
+	}
+	data = body[:length]
+
+	valid := (op == nodeFilePut || (op == nodeFileDelete && length == 0)) &&
+		binary.BigEndian.Uint32(body[length:]) == crc32.Update(crc32.Checksum(head, crcTable), crcTable, data)
+	switch {
+	case !valid && n == remaining:
+		err = errTornNodeFile
+	case !valid:
+		err = errCorruptNodeFile
+	}
+
+	return
+}
+
+//Encode an operation with its checksum
+func encodeFileOp(op byte, id uint64, data []byte) []byte {
+	out := []byte{op}
+	out = binary.AppendUvarint(out, id)
+	out = binary.AppendUvarint(out, uint64(len(data)))
+	out = append(out, data...)
+	return binary.BigEndian.AppendUint32(out, crc32.Checksum(out, crcTable))
+}
+
+//Append the operation to the file, the size is only advanced once the
+// whole operation is written so a failed write is overwritten by the next
+func (db *FileNodeDB) append(op []byte) (offset int64, err error) {
+	if db.file == nil {
+		return 0, errClosedNodeFile
+	}
+	if _, err = db.file.WriteAt(op, db.size); err != nil {
+		return 0, err
+	}
+	offset = db.size
+	db.size += int64(len(op))
+	return
+}
+
+func (db *FileNodeDB) Get(id uint64) (data []byte, err error) {
+	db.mtx.RLock()
+	defer db.mtx.RUnlock()
+
+	if db.file == nil {
+		return nil, errClosedNodeFile
+	}
+
+	record, ok := db.index[id]
+	if !ok {
+		return nil, nil
+	}
+
+	data = make([]byte, record.length)
+	if _, err = db.file.ReadAt(data, record.offset); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (db *FileNodeDB) Put(id uint64, data []byte) error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+
+	op := encodeFileOp(nodeFilePut, id, data)
+	offset, err := db.append(op)
+	if err != nil {
+		return err
+	}
+
+	db.setRecord(id, fileRecord{
+		offset: offset + int64(len(op)-4-len(data)),
+		length: len(data),
+	})
+	return nil
+}
+
+func (db *FileNodeDB) Delete(id uint64) error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+
+	if _, ok := db.index[id]; !ok {
+		return nil
+	}
+
+	if _, err := db.append(encodeFileOp(nodeFileDelete, id, nil)); err != nil {
+		return err
+	}
+	db.deleteRecord(id)
+
+	if reclaimable := db.size - db.live; reclaimable > db.live && reclaimable > nodeFileCompactMin {
+		return db.compact()
+	}
+	return nil
+}
+
+//Index the record, replacing any previous record of the ID
+func (db *FileNodeDB) setRecord(id uint64, record fileRecord) {
+	db.deleteRecord(id)
+	db.index[id] = record
+	db.live += fileOpLength(id, record.length)
+}
+
+func (db *FileNodeDB) deleteRecord(id uint64) {
+	if record, ok := db.index[id]; ok {
+		db.live -= fileOpLength(id, record.length)
+		delete(db.index, id)
+	}
+}
+
+//Returns the length of the operation putting a record
+func fileOpLength(id uint64, length int) int64 {
+	var scratch [binary.MaxVarintLen64]byte
+	return int64(1 + binary.PutUvarint(scratch[:], id) + binary.PutUvarint(scratch[:], uint64(length)) + length + 4)
+}
+
+//Compact the file by writing the indexed records to a new file which replaces it
+func (db *FileNodeDB) Compact() error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+
+	if db.file == nil {
+		return errClosedNodeFile
+	}
+	return db.compact()
+}
+
+func (db *FileNodeDB) compact() (err error) {
+
+	file, err := os.OpenFile(db.path+".compact", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			file.Close()
+			os.Remove(file.Name())
+		}
+	}()
+
+	buf := bufio.NewWriter(file)
+	buf.Write(nodeFileMagic)
+	buf.WriteByte(nodeFileVersion)
+	size := int64(len(nodeFileMagic) + 1)
+
+	index := make(map[uint64]fileRecord, len(db.index))
+	for id, record := range db.index {
+		data := make([]byte, record.length)
+		if _, err = db.file.ReadAt(data, record.offset); err != nil {
+			return err
+		}
+
+		op := encodeFileOp(nodeFilePut, id, data)
+		if _, err = buf.Write(op); err != nil {
+			return err
+		}
+		index[id] = fileRecord{
+			offset: size + int64(len(op)-4-len(data)),
+			length: len(data),
+		}
+		size += int64(len(op))
+	}
+
+	//The new file is only moved into place once it is stable
+	if err = buf.Flush(); err != nil {
+		return err
+	}
+	if err = file.Sync(); err != nil {
+		return err
+	}
+	if err = os.Rename(file.Name(), db.path); err != nil {
+		return err
+	}
+
+	db.file.Close()
+	db.file = file
+	db.size = size
+	db.index = index
+
+	return nil
+}
+
+//Returns the number of records held
+func (db *FileNodeDB) Len() int {
+	db.mtx.RLock()
+	defer db.mtx.RUnlock()
+
+	return len(db.index)
+}
+
+//Commit the written operations to stable storage
+func (db *FileNodeDB) Sync() error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+
+	if db.file == nil {
+		return errClosedNodeFile
+	}
+	return db.file.Sync()
+}
+
+//Close the file, the database cannot be used afterwards
+func (db *FileNodeDB) Close() error {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+
+	if db.file == nil {
+		return errClosedNodeFile
+	}
+	err := db.file.Close()
+	db.file = nil
+	return err
+}
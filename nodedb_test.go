@@ -0,0 +1,545 @@
+package AVL_Tree
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNodeDB(t *testing.T) {
+
+	printErr := func(err error) {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	//Test the records held by a node database against the expected records
+	checkRecords := func(name string, db NodeDB, records map[uint64]string) {
+		for id := uint64(0); id < 10; id++ {
+			data, err := db.Get(id)
+			printErr(err)
+			expd, exists := records[id]
+			if (data != nil) != exists || string(data) != expd {
+				t.Errorf("bad record %v for %v, expected %q found %q", id, name, expd, data)
+			}
+		}
+	}
+
+	//Put and delete records, returning the expected records
+	nodeDBTest := func(name string, db NodeDB) map[uint64]string {
+		records := make(map[uint64]string)
+		for id := uint64(0); id < 6; id++ {
+			records[id] = fmt.Sprintf("record%d", id)
+			printErr(db.Put(id, []byte(records[id])))
+		}
+		records[7] = ""
+		printErr(db.Put(7, []byte{}))
+
+		records[2] = "replaced"
+		printErr(db.Put(2, []byte(records[2])))
+		delete(records, 3)
+		printErr(db.Delete(3))
+		printErr(db.Delete(9))
+
+		checkRecords(name, db, records)
+		return records
+	}
+
+	nodeDBTest("memory", NewMemNodeDB())
+
+	//Test reopening the file database
+	path := filepath.Join(t.TempDir(), "nodes")
+	db, err := OpenFileNodeDB(path)
+	printErr(err)
+	records := nodeDBTest("file", db)
+	printErr(db.Close())
+
+	db, err = OpenFileNodeDB(path)
+	printErr(err)
+	checkRecords("reopened file", db, records)
+	printErr(db.Close())
+
+	//Test that a torn operation at the end of the file is truncated
+	fileBytes, err := os.ReadFile(path)
+	printErr(err)
+	torn := encodeFileOp(nodeFilePut, 8, []byte("torn"))
+	printErr(os.WriteFile(path, append(fileBytes, torn[:len(torn)-2]...), 0644))
+
+	db, err = OpenFileNodeDB(path)
+	printErr(err)
+	checkRecords("torn file", db, records)
+	records[8] = "after"
+	printErr(db.Put(8, []byte(records[8])))
+	printErr(db.Close())
+
+	db, err = OpenFileNodeDB(path)
+	printErr(err)
+	checkRecords("file written after truncation", db, records)
+	printErr(db.Close())
+
+	//Test that corruption before the end of the file is detected
+	fileBytes, err = os.ReadFile(path)
+	printErr(err)
+	fileBytes[len(nodeFileMagic)+4] ^= 0xff
+	printErr(os.WriteFile(path, fileBytes, 0644))
+	if _, err = OpenFileNodeDB(path); err != errCorruptNodeFile {
+		t.Errorf("expected a corrupt file error, found %v", err)
+	}
+
+	printErr(os.WriteFile(path, []byte("not a node database"), 0644))
+	if _, err = OpenFileNodeDB(path); err != errBadNodeFile {
+		t.Errorf("expected a bad file error, found %v", err)
+	}
+}
+
+func TestFileNodeDBCompaction(t *testing.T) {
+
+	printErr := func(err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "nodes")
+	db, err := OpenFileNodeDB(path)
+	printErr(err)
+
+	fileSize := func() int64 {
+		info, err := os.Stat(path)
+		printErr(err)
+		return info.Size()
+	}
+
+	//Records which are deleted are reclaimed once they take up most of the file
+	record := bytes.Repeat([]byte("r"), 10000)
+	for id := uint64(1); id <= 200; id++ {
+		printErr(db.Put(id, append([]byte(fmt.Sprint(id)), record...)))
+	}
+	for id := uint64(1); id <= 150; id++ {
+		printErr(db.Delete(id))
+	}
+	if size := fileSize(); size > 100*10100 {
+		t.Errorf("expected the file to be compacted, found size %v", size)
+	}
+
+	checkRecords := func(name string) {
+		if db.Len() != 50 {
+			t.Errorf("bad record count %v, expected 50 found %v", name, db.Len())
+		}
+		for id := uint64(1); id <= 200; id++ {
+			data, err := db.Get(id)
+			printErr(err)
+			if expd := append([]byte(fmt.Sprint(id)), record...); (id > 150) != bytes.Equal(data, expd) {
+				t.Errorf("bad record %v %v", id, name)
+			}
+		}
+	}
+	checkRecords("after compaction")
+
+	//Test compacting explicitly, and writing to the compacted file
+	printErr(db.Put(201, []byte("new")))
+	printErr(db.Delete(201))
+	printErr(db.Compact())
+	if size := fileSize(); size != db.live+int64(len(nodeFileMagic)+1) {
+		t.Errorf("bad size after compacting, expected %v found %v", db.live+int64(len(nodeFileMagic)+1), size)
+	}
+	printErr(db.Close())
+
+	db, err = OpenFileNodeDB(path)
+	printErr(err)
+	checkRecords("after reopening")
+	printErr(db.Close())
+}
+
+func TestNodeDBTree(t *testing.T) {
+
+	printErr := func(err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "nodes")
+	db, err := OpenFileNodeDB(path)
+	printErr(err)
+
+	//The tree saved to the database with a small cache, checked against an
+	// in-memory tree with the same operations, and the expected records
+	const cacheSize = 16
+	tr := NewAVLTree(WithNodeDB(db), WithCacheSize(cacheSize))
+	memTr := NewAVLTree()
+	records := make(map[string]string)
+
+	checkHash := func(op string) {
+		hash, _ := tr.GetHash()
+		expdHash, _ := memTr.GetHash()
+		if !bytes.Equal(hash, expdHash) {
+			t.Fatalf("bad hash after %v, expected %x found %x", op, expdHash, hash)
+		}
+		if tr.store.lru.Len() > cacheSize {
+			t.Fatalf("bad cache after %v, %v nodes resident", op, tr.store.lru.Len())
+		}
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		key := fmt.Sprintf("k%03d", rnd.Intn(200))
+		value := fmt.Sprintf("v%d", i)
+
+		var op string
+		switch rnd.Intn(4) {
+		case 0, 1:
+			op = "set " + key
+			printErr(tr.Set([]byte(key), []byte(value)))
+			printErr(memTr.Set([]byte(key), []byte(value)))
+			records[key] = value
+		case 2:
+			op = "remove " + key
+			err := tr.Remove([]byte(key))
+			if expdErr := memTr.Remove([]byte(key)); err != expdErr {
+				t.Fatalf("bad error for %v, expected %v found %v", op, expdErr, err)
+			}
+			delete(records, key)
+		case 3:
+			op = "get " + key
+			value, err := tr.Get([]byte(key))
+			if expdValue, exists := records[key]; string(value) != expdValue || (err == nil) != exists {
+				t.Fatalf("bad value for %v, expected %v found %v %v", op, expdValue, string(value), err)
+			}
+		}
+		checkHash(op)
+
+		//Only the records of the saved tree remain, along with the metadata record
+		if i%50 == 0 {
+			_, err := tr.Save()
+			printErr(err)
+			checkHash("save")
+			if db.Len() != tr.Size()+1 {
+				t.Fatalf("bad record count after save, expected %v found %v", tr.Size()+1, db.Len())
+			}
+		}
+	}
+	_, err = tr.Save()
+	printErr(err)
+	printErr(db.Close())
+
+	//Test loading the saved tree from the reopened file
+	db, err = OpenFileNodeDB(path)
+	printErr(err)
+	tr, err = LoadAVLTree(WithNodeDB(db), WithCacheSize(cacheSize))
+	printErr(err)
+	if tr.Size() != len(records) || tr.store.lru.Len() != 0 {
+		t.Errorf("expected an unloaded trunk holding %v records", len(records))
+	}
+	checkHash("load")
+
+	for key, value := range records {
+		recievedVal, err := tr.Get([]byte(key))
+		if err != nil || string(recievedVal) != value {
+			t.Errorf("bad value for %v after load, expected %v found %v", key, value, string(recievedVal))
+		}
+	}
+	checkHash("get after load")
+	printErr(db.Close())
+
+	//Test loading without a node database
+	if _, err = LoadAVLTree(); err != errNoNodeDB {
+		t.Errorf("expected a no node database error, found %v", err)
+	}
+}
+
+func TestNodeDBOrderQueries(t *testing.T) {
+
+	printErr := func(err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//Keys k000, k002 ... k198 so that there are gaps between every key
+	db := NewMemNodeDB()
+	memTr := NewAVLTree()
+	tr := NewAVLTree(WithNodeDB(db))
+	for i := 0; i < 200; i += 2 {
+		key := []byte(fmt.Sprintf("k%03d", i))
+		printErr(tr.Add(key, []byte("v")))
+		printErr(memTr.Add(key, []byte("v")))
+	}
+	_, err := tr.Save()
+	printErr(err)
+
+	//Each query is made on a newly loaded tree with a small cache, so that
+	// the nodes it reaches are loaded by the query itself
+	loadTree := func() AVLTree {
+		tr, err := LoadAVLTree(WithNodeDB(db), WithCacheSize(4))
+		printErr(err)
+		return tr
+	}
+
+	type query func(tr *AVLTree, key []byte) ([]byte, []byte, error)
+	queries := map[string]query{
+		"Floor":   (*AVLTree).Floor,
+		"Ceiling": (*AVLTree).Ceiling,
+		"Lower":   (*AVLTree).Lower,
+		"Higher":  (*AVLTree).Higher,
+		"Min":     func(tr *AVLTree, _ []byte) ([]byte, []byte, error) { return tr.Min() },
+		"Max":     func(tr *AVLTree, _ []byte) ([]byte, []byte, error) { return tr.Max() },
+	}
+
+	for i := -1; i <= 200; i++ {
+		key := []byte(fmt.Sprintf("k%03d", i))
+
+		tr = loadTree()
+		if rank, expdRank := tr.Rank(key), memTr.Rank(key); rank != expdRank {
+			t.Errorf("bad rank for %s, expected %v found %v", key, expdRank, rank)
+		}
+
+		tr = loadTree()
+		indexKey, _, err := tr.GetByIndex(i)
+		expdKey, _, expdErr := memTr.GetByIndex(i)
+		if err != expdErr || !bytes.Equal(indexKey, expdKey) {
+			t.Errorf("bad record at index %v, expected %s %v found %s %v", i, expdKey, expdErr, indexKey, err)
+		}
+
+		for name, q := range queries {
+			tr = loadTree()
+			foundKey, _, err := q(&tr, key)
+			expdKey, _, expdErr := q(&memTr, key)
+			if err != expdErr || !bytes.Equal(foundKey, expdKey) {
+				t.Errorf("bad result for %v(%s), expected %s %v found %s %v", name, key, expdKey, expdErr, foundKey, err)
+			}
+			if tr.store.lru.Len() > 4 {
+				t.Errorf("bad cache after %v(%s), %v nodes resident", name, key, tr.store.lru.Len())
+			}
+		}
+	}
+}
+
+func TestNodeDBIteration(t *testing.T) {
+
+	printErr := func(err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db := NewMemNodeDB()
+	tr := NewAVLTree(WithNodeDB(db))
+	for i := 0; i < 200; i++ {
+		printErr(tr.Add([]byte(fmt.Sprintf("k%03d", i)), []byte("v")))
+	}
+	_, err := tr.Save()
+	printErr(err)
+
+	//Test iterating a newly loaded tree with a small cache while reading
+	// the tree at each step, which must not unload the nodes being iterated
+	const cacheSize = 4
+	iterTest := func(name string, iterate func(tr *AVLTree, fn func(key []byte))) {
+		tr, err := LoadAVLTree(WithNodeDB(db), WithCacheSize(cacheSize))
+		printErr(err)
+
+		count := 0
+		iterate(&tr, func(key []byte) {
+			if expdKey := fmt.Sprintf("k%03d", count); string(key) != expdKey {
+				t.Fatalf("bad key for %v, expected %v found %s", name, expdKey, key)
+			}
+			_, err := tr.Get([]byte(fmt.Sprintf("k%03d", 199-count)))
+			printErr(err)
+			count++
+		})
+		if count != 200 {
+			t.Errorf("bad key count for %v, expected 200 found %v", name, count)
+		}
+
+		//Nodes are unloaded again once iteration is finished
+		_, err = tr.Get([]byte("k000"))
+		printErr(err)
+		if tr.store.pins != 0 || tr.store.lru.Len() > cacheSize {
+			t.Errorf("bad cache after %v, %v nodes resident with %v pins", name, tr.store.lru.Len(), tr.store.pins)
+		}
+	}
+
+	iterTest("iterator", func(tr *AVLTree, fn func(key []byte)) {
+		for it := tr.Iterator(nil, nil, true); it.Valid(); it.Next() {
+			fn(it.Key())
+		}
+	})
+	iterTest("sequence", func(tr *AVLTree, fn func(key []byte)) {
+		for key := range tr.All() {
+			fn(key)
+		}
+	})
+	iterTest("prefix iteration", func(tr *AVLTree, fn func(key []byte)) {
+		tr.PrefixIterate([]byte("k"), func(key, _ []byte) bool {
+			fn(key)
+			return true
+		})
+	})
+
+	//An iterator closed before it is exhausted no longer keeps nodes loaded
+	it := tr.Iterator(nil, nil, true)
+	it.Next()
+	it.Close()
+	if tr.store.pins != 0 {
+		t.Errorf("expected no pins after closing the iterator, found %v", tr.store.pins)
+	}
+}
+
+//Node database which fails all functions while failing is set
+type failingNodeDB struct {
+	NodeDB
+	failing bool
+}
+
+var errTestFailure error = errors.New("Test failure")
+
+func (db *failingNodeDB) Get(id uint64) ([]byte, error) {
+	if db.failing {
+		return nil, errTestFailure
+	}
+	return db.NodeDB.Get(id)
+}
+
+func TestNodeDBFailure(t *testing.T) {
+
+	printErr := func(err error) {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	db := &failingNodeDB{NodeDB: NewMemNodeDB()}
+	tr := NewAVLTree(WithNodeDB(db))
+	for i := 0; i < 20; i++ {
+		printErr(tr.Add([]byte(fmt.Sprintf("k%02d", i)), []byte("v")))
+	}
+	_, err := tr.Save()
+	printErr(err)
+
+	tr, err = LoadAVLTree(WithNodeDB(db))
+	printErr(err)
+
+	//Test that the load error is returned, and kept for all further functions
+	db.failing = true
+	if _, err = tr.Get([]byte("k05")); err != errTestFailure {
+		t.Errorf("expected the load error from get, found %v", err)
+	}
+	db.failing = false
+	if err = tr.Add([]byte("k99"), []byte("v")); err != errTestFailure {
+		t.Errorf("expected the load error from add, found %v", err)
+	}
+	if _, err = tr.Save(); err != errTestFailure {
+		t.Errorf("expected the load error from save, found %v", err)
+	}
+
+	//Reloading recovers the tree
+	tr, err = LoadAVLTree(WithNodeDB(db))
+	printErr(err)
+	value, err := tr.Get([]byte("k05"))
+	if err != nil || string(value) != "v" {
+		t.Errorf("bad value after reloading, found %v %v", string(value), err)
+	}
+
+	//Test that all read functions return the load error, or report it through
+	// Err or Error if they have no error return, and keep it for further functions
+	reads := map[string]func(tr *AVLTree) error{
+		"GetWithProof": func(tr *AVLTree) (err error) {
+			_, _, err = tr.GetWithProof([]byte("k05"))
+			return
+		},
+		"GetAbsenceProof": func(tr *AVLTree) (err error) {
+			_, err = tr.GetAbsenceProof([]byte("k055"))
+			return
+		},
+		"GetRangeWithProof": func(tr *AVLTree) (err error) {
+			_, _, _, err = tr.GetRangeWithProof(nil, nil)
+			return
+		},
+		"GetMultiWithProof": func(tr *AVLTree) (err error) {
+			_, _, err = tr.GetMultiWithProof([][]byte{[]byte("k05")})
+			return
+		},
+		"GetByIndex": func(tr *AVLTree) (err error) {
+			_, _, err = tr.GetByIndex(5)
+			return
+		},
+		"Floor": func(tr *AVLTree) (err error) {
+			_, _, err = tr.Floor([]byte("k05"))
+			return
+		},
+		"Min": func(tr *AVLTree) (err error) {
+			_, _, err = tr.Min()
+			return
+		},
+		"WriteTo": func(tr *AVLTree) (err error) {
+			_, err = tr.WriteTo(&bytes.Buffer{})
+			return
+		},
+		"Rank": func(tr *AVLTree) error {
+			if rank := tr.Rank([]byte("k05")); rank != 0 {
+				t.Errorf("expected a rank of 0 after a failed load, found %v", rank)
+			}
+			return tr.Err()
+		},
+		"Iterator": func(tr *AVLTree) error {
+			it := tr.Iterator(nil, nil, true)
+			if it.Valid() {
+				t.Errorf("expected the iterator to be invalid after a failed load")
+			}
+			return it.Error()
+		},
+		"Next": func(tr *AVLTree) error {
+			it := tr.Iterator(nil, nil, true)
+			db.failing = true
+			for ; it.Valid(); it.Next() {
+			}
+			if tr.store.pins != 0 {
+				t.Errorf("expected no pins after a failed load, found %v", tr.store.pins)
+			}
+			return it.Error()
+		},
+		"All": func(tr *AVLTree) error {
+			for range tr.All() {
+				db.failing = true
+			}
+			return tr.Err()
+		},
+		"PrefixIterate": func(tr *AVLTree) error {
+			tr.PrefixIterate([]byte("k"), func(_, _ []byte) bool {
+				db.failing = true
+				return true
+			})
+			return tr.Err()
+		},
+		"TreeStructure": func(tr *AVLTree) error {
+			if structure := tr.TreeStructure(); structure != "" {
+				t.Errorf("expected an empty tree structure after a failed load")
+			}
+			return tr.Err()
+		},
+	}
+	for name, read := range reads {
+		tr, err = LoadAVLTree(WithNodeDB(db))
+		printErr(err)
+		if tr.Err() != nil {
+			t.Errorf("expected no load error after loading the tree, found %v", tr.Err())
+		}
+
+		//Functions which iterate begin failing part way through
+		db.failing = name != "Next" && name != "All" && name != "PrefixIterate"
+		err = read(&tr)
+		db.failing = false
+
+		if err != errTestFailure {
+			t.Errorf("expected the load error from %v, found %v", name, err)
+		}
+		if _, err = tr.Get([]byte("k05")); err != errTestFailure {
+			t.Errorf("expected the load error to be kept after %v, found %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package AVL_Tree
+
+//Options are used to configure a tree when it is created,
+// and to configure proof verification to match the tree
+type Option func(*config)
+
+//Configuration shared by a tree and the verification of its proofs
+type config struct {
+	scheme      HashScheme
+	hasher      Hasher
+	db          NodeDB
+	cacheSize   int
+	retention   RetentionPolicy
+	hashWorkers int
+}
+
+//Generate the configuration from the default values and any options passed in
+func newConfig(opts []Option) config {
+
+	c := config{
+		scheme:    HashSchemeDomainSeparated,
+		hasher:    SHA3Hasher{},
+		cacheSize: defaultCacheSize,
+	}
+
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	return c
+}
+
+//Set the scheme used to calculate the merkle hash of each node
+func WithHashScheme(scheme HashScheme) Option {
+	return func(c *config) {
+		c.scheme = scheme
+	}
+}
+
+//Set the hash function used to calculate the merkle hash of each node
+func WithHasher(hasher Hasher) Option {
+	return func(c *config) {
+		c.hasher = hasher
+	}
+}
+
+//Set the node database which the tree is saved to and loaded from,
+// nodes which have been saved are unloaded from memory as needed
+func WithNodeDB(db NodeDB) Option {
+	return func(c *config) {
+		c.db = db
+	}
+}
+
+//Set the maximum number of saved nodes kept resident in memory
+// between operations of a tree with a node database
+func WithCacheSize(size int) Option {
+	return func(c *config) {
+		c.cacheSize = size
+	}
+}
+
+//Set the number of goroutines used to hash the changed nodes of large subtrees in parallel,
+// hashing is serial if less than 2. The hasher must be safe for concurrent use.
+func WithParallelHashing(workers int) Option {
+	return func(c *config) {
+		c.hashWorkers = workers
+	}
+}
+
+//Policy for which versions are kept when a new version is saved. The latest version
+// and the version the tree is based on are always kept, and all versions are kept
+// if both fields are 0.
+type RetentionPolicy struct {
+	KeepRecent int64 //number of the latest versions kept, if not 0
+	KeepEvery  int64 //versions which are a multiple of this are also kept, if not 0
+}
+
+//Set the policy for which versions are kept when a new version is saved,
+// all other versions are deleted
+func WithRetention(policy RetentionPolicy) Option {
+	return func(c *config) {
+		c.retention = policy
+	}
+}
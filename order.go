@@ -0,0 +1,178 @@
+package AVL_Tree
+
+import (
+	"bytes"
+	"errors"
+)
+
+//errors used for order queries
+var errBadIndex error = errors.New("Index out of range")
+var errNoMatch error = errors.New("No key found matching the query")
+
+//Does the error report that no key satisfies an order query?
+// This is distinct from the error generated by queries on an empty tree.
+func IsNoMatch(err error) bool {
+	return errors.Is(err, errNoMatch)
+}
+
+//Does the error report that the tree is empty?
+func IsEmptyTree(err error) bool {
+	return errors.Is(err, errEmptyTree)
+}
+
+//Returns the number of key-value pairs held within the tree
+func (t *AVLTree) Size() int {
+	return t.trunk.size
+}
+
+//Returns the number of keys within the tree which are less than the key,
+// this is the index of the key in key order if it is held within the tree.
+// If loading a node fails the rank is 0 and the failure is returned by Err.
+func (t *AVLTree) Rank(key []byte) (rank int) {
+	if t.storeErr() != nil {
+		return
+	}
+	defer t.finishStore(new(error))
+
+	//The rank is only set once the search is complete,
+	// so that a failed load part way through returns 0
+	less := 0
+	n := t.trunk
+	for !n.isPlaceholder() {
+
+		//Compare(a,b) will be 0 if a==b, -1 if a < b, and +1 if a > b
+		switch bytes.Compare(key, n.key) {
+		case 0:
+			return less + n.leftNode.size
+		case -1:
+			n = n.leftNode
+		case 1:
+			less += n.leftNode.size + 1
+			n = n.rightNode
+		}
+	}
+
+	return less
+}
+
+//Get the key-value pair at the index in key order
+func (t *AVLTree) GetByIndex(index int) (key, value []byte, err error) {
+	if err = t.storeErr(); err != nil {
+		return
+	}
+	defer t.finishStore(&err)
+
+	if t.trunk.isPlaceholder() {
+		err = errEmptyTree
+		return
+	}
+
+	if index < 0 || index >= t.trunk.size {
+		err = errBadIndex
+		return
+	}
+
+	//The index is within range so a placeholder is never reached,
+	// checking each node loads it before its children are read
+	n := t.trunk
+	for !n.isPlaceholder() {
+		leftSize := n.leftNode.size
+
+		switch {
+		case index < leftSize:
+			n = n.leftNode
+		case index == leftSize:
+			key, value = n.key, n.value
+			return
+		default:
+			index -= leftSize + 1
+			n = n.rightNode
+		}
+	}
+
+	return
+}
+
+//Get the key-value pair with the least key
+func (t *AVLTree) Min() (key, value []byte, err error) {
+	if err = t.storeErr(); err != nil {
+		return
+	}
+	defer t.finishStore(&err)
+
+	if t.trunk.isPlaceholder() {
+		err = errEmptyTree
+		return
+	}
+	return record(t.trunk.findMin())
+}
+
+//Get the key-value pair with the greatest key
+func (t *AVLTree) Max() (key, value []byte, err error) {
+	if err = t.storeErr(); err != nil {
+		return
+	}
+	defer t.finishStore(&err)
+
+	if t.trunk.isPlaceholder() {
+		err = errEmptyTree
+		return
+	}
+	return record(t.trunk.findMax())
+}
+
+//Get the key-value pair with the greatest key less than or equal to the key
+func (t *AVLTree) Floor(key []byte) (floorKey, value []byte, err error) {
+	return t.neighbour(key, true, false)
+}
+
+//Get the key-value pair with the least key greater than or equal to the key
+func (t *AVLTree) Ceiling(key []byte) (ceilingKey, value []byte, err error) {
+	return t.neighbour(key, true, true)
+}
+
+//Get the key-value pair with the greatest key strictly less than the key
+func (t *AVLTree) Lower(key []byte) (lowerKey, value []byte, err error) {
+	return t.neighbour(key, false, false)
+}
+
+//Get the key-value pair with the least key strictly greater than the key
+func (t *AVLTree) Higher(key []byte) (higherKey, value []byte, err error) {
+	return t.neighbour(key, false, true)
+}
+
+//Get the key-value pair neighbouring the key in the direction given by greater.
+// If inclusive is true a matching key is returned itself. A search for a missing
+// key lands on a placeholder whose successor or predecessor is the neighbour.
+func (t *AVLTree) neighbour(key []byte, inclusive, greater bool) (neighbourKey, value []byte, err error) {
+	if err = t.storeErr(); err != nil {
+		return
+	}
+	defer t.finishStore(&err)
+
+	if t.trunk.isPlaceholder() {
+		err = errEmptyTree
+		return
+	}
+
+	n := t.trunk.findNode(key)
+
+	switch {
+	case inclusive && !n.isPlaceholder():
+	case greater:
+		n = n.next()
+	default:
+		n = n.prev()
+	}
+
+	return record(n)
+}
+
+//Returns the record of the node, or an error if there is no node
+func record(n *node) (key, value []byte, err error) {
+	if n == nil {
+		err = errNoMatch
+		return
+	}
+	return n.key, n.value, nil
+}
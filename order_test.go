@@ -0,0 +1,146 @@
+package AVL_Tree
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestOrderStatistics(t *testing.T) {
+
+	//The AVLTree to be tested with, and the expected keys held within it
+	tr := NewAVLTree()
+	records := make(map[string]bool)
+
+	//Test retrieving from an empty tree
+	if tr.Size() != 0 || tr.Rank([]byte("a")) != 0 {
+		t.Errorf("expected an empty tree to have no size or rank")
+	}
+	if _, _, err := tr.GetByIndex(0); err != errEmptyTree {
+		t.Errorf("expected an empty tree error, found %v", err)
+	}
+
+	//Test the size, rank, and index of every key against the sorted keys
+	orderTest := func() {
+		var keys []string
+		for key := range records {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		if tr.Size() != len(keys) {
+			t.Fatalf("bad size, expected %v found %v", len(keys), tr.Size())
+		}
+
+		for i, key := range keys {
+			if rank := tr.Rank([]byte(key)); rank != i {
+				t.Fatalf("bad rank for %v, expected %v found %v", key, i, rank)
+			}
+
+			//Keys between the held keys rank with the following key
+			if rank := tr.Rank([]byte(key + "0")); rank != i+1 {
+				t.Fatalf("bad rank for %v0, expected %v found %v", key, i+1, rank)
+			}
+
+			indexKey, value, err := tr.GetByIndex(i)
+			if err != nil || string(indexKey) != key || string(value) != "v"+key {
+				t.Fatalf("bad record at index %v, expected %v found %v %v", i, key, string(indexKey), err)
+			}
+		}
+
+		for _, index := range []int{-1, len(keys)} {
+			if _, _, err := tr.GetByIndex(index); err != errBadIndex && len(keys) > 0 {
+				t.Fatalf("expected a bad index error for index %v, found %v", index, err)
+			}
+		}
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		key := fmt.Sprintf("k%02d", rnd.Intn(64))
+
+		if rnd.Intn(3) == 0 {
+			tr.Remove([]byte(key))
+			delete(records, key)
+		} else {
+			tr.Set([]byte(key), []byte("v"+key))
+			records[key] = true
+		}
+
+		orderTest()
+	}
+}
+
+func TestNeighbourQueries(t *testing.T) {
+
+	//The AVLTree to be tested with
+	tr := NewAVLTree()
+
+	printErr := func(err error) {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	type query func(key []byte) ([]byte, []byte, error)
+	queries := map[string]query{
+		"Floor":   tr.Floor,
+		"Ceiling": tr.Ceiling,
+		"Lower":   tr.Lower,
+		"Higher":  tr.Higher,
+		"Min":     func([]byte) ([]byte, []byte, error) { return tr.Min() },
+		"Max":     func([]byte) ([]byte, []byte, error) { return tr.Max() },
+	}
+
+	//Test all queries on an empty tree
+	for name, q := range queries {
+		_, _, err := q([]byte("b"))
+		if !IsEmptyTree(err) || IsNoMatch(err) {
+			t.Errorf("expected an empty tree error for %v, found %v", name, err)
+		}
+	}
+
+	for _, key := range []string{"b", "d", "f", "h", "j"} {
+		printErr(tr.Add([]byte(key), []byte("v"+key)))
+	}
+
+	//Test a query against the expected key, an empty
+	// expected key is used where no key should be found
+	queryTest := func(name, key, expdKey string) {
+		foundKey, value, err := queries[name]([]byte(key))
+		if expdKey == "" {
+			if !IsNoMatch(err) || IsEmptyTree(err) {
+				t.Errorf("expected no match for %v(%v), found %v %v", name, key, string(foundKey), err)
+			}
+			return
+		}
+		printErr(err)
+		if string(foundKey) != expdKey || string(value) != "v"+expdKey {
+			t.Errorf("bad result for %v(%v), expected %v found %v", name, key, expdKey, string(foundKey))
+		}
+	}
+
+	queryTest("Min", "", "b")
+	queryTest("Max", "", "j")
+
+	queryTest("Floor", "a", "")
+	queryTest("Floor", "b", "b")
+	queryTest("Floor", "c", "b")
+	queryTest("Floor", "k", "j")
+
+	queryTest("Ceiling", "a", "b")
+	queryTest("Ceiling", "d", "d")
+	queryTest("Ceiling", "e", "f")
+	queryTest("Ceiling", "k", "")
+
+	queryTest("Lower", "b", "")
+	queryTest("Lower", "d", "b")
+	queryTest("Lower", "e", "d")
+	queryTest("Lower", "k", "j")
+
+	queryTest("Higher", "a", "b")
+	queryTest("Higher", "f", "h")
+	queryTest("Higher", "g", "h")
+	queryTest("Higher", "j", "")
+}
@@ -0,0 +1,402 @@
+package AVL_Tree
+
+import (
+	"bytes"
+	"iter"
+)
+
+//Persistent variant of the AVL tree. Nodes are never modified once created,
+// changes copy the path from the trunk to the changed node, so trees share all
+// unchanged subtrees and a snapshot is a copy of the trunk reference.
+// Rotations follow those of AVLTree, so the same changes produce the same merkle hash.
+type PersistentTree struct {
+	trunk  *persistentNode //nil for an empty tree
+	config config
+}
+
+//Immutable node of a persistent tree. Nodes hold no parent so that
+// they can be shared, and nil is used for empty children.
+type persistentNode struct {
+	key       []byte
+	value     []byte
+	height    int
+	size      int
+	hash      []byte
+	leftNode  *persistentNode
+	rightNode *persistentNode
+}
+
+//Create a new empty persistent tree, options may be passed in to configure the tree
+func NewPersistentTree(opts ...Option) PersistentTree {
+	return PersistentTree{
+		config: newConfig(opts),
+	}
+}
+
+//Returns a snapshot of the tree in constant time, the snapshot and the tree share
+// all of their nodes and neither is affected by later changes to the other
+func (t *PersistentTree) Snapshot() PersistentTree {
+	return *t
+}
+
+//Returns the merkle root hash (hash of the trunk node)
+func (t *PersistentTree) GetHash() (hash []byte, err error) {
+	if t.trunk == nil {
+		return nil, errEmptyTree
+	}
+	return t.trunk.hash, nil
+}
+
+//Returns the number of key-value pairs held within the tree
+func (t *PersistentTree) Size() int {
+	return t.trunk.getSize()
+}
+
+//Get a value from the tree from an existing key
+func (t *PersistentTree) Get(key []byte) (value []byte, err error) {
+	if t.trunk == nil {
+		return nil, errEmptyTree
+	}
+
+	n := t.trunk
+	for n != nil {
+
+		//Compare(a,b) will be 0 if a==b, -1 if a < b, and +1 if a > b
+		switch bytes.Compare(key, n.key) {
+		case 0:
+			return n.value, nil
+		case -1:
+			n = n.leftNode
+		case 1:
+			n = n.rightNode
+		}
+	}
+
+	return nil, errBadKey
+}
+
+//Adds the value if it doesn't exist, if it exists in updates the value
+func (t *PersistentTree) Set(key, value []byte) error {
+	err := t.Add(key, value)
+	if err == errDupVal {
+		return t.Update(key, value)
+	}
+	return err
+}
+
+//Update a value from the tree for an already existing key
+func (t *PersistentTree) Update(key, value []byte) error {
+	if t.trunk == nil {
+		return errEmptyTree
+	}
+
+	trunk, err := t.update(t.trunk, key, value)
+	if err != nil {
+		return err
+	}
+	t.trunk = trunk
+
+	return nil
+}
+
+//Add a new key-value to the tree for a non-existent key
+func (t *PersistentTree) Add(key, value []byte) error {
+	trunk, err := t.add(t.trunk, key, value)
+	if err != nil {
+		return err
+	}
+	t.trunk = trunk
+
+	return nil
+}
+
+//Remove a key-value pair from the tree
+func (t *PersistentTree) Remove(key []byte) error {
+	if t.trunk == nil {
+		return errEmptyTree
+	}
+
+	trunk, err := t.remove(t.trunk, key)
+	if err != nil {
+		return err
+	}
+	t.trunk = trunk
+
+	return nil
+}
+
+//Returns the tree structure in the same format as AVLTree
+func (t *PersistentTree) TreeStructure() string {
+	return t.trunk.outputStructure(nil)
+}
+
+//Returns a sequence over all key-value pairs in ascending key order
+func (t *PersistentTree) All() iter.Seq2[[]byte, []byte] {
+	return t.seq(nil, nil, true)
+}
+
+//Returns a sequence over all key-value pairs in descending key order
+func (t *PersistentTree) Backward() iter.Seq2[[]byte, []byte] {
+	return t.seq(nil, nil, false)
+}
+
+//Returns a sequence over the key-value pairs with keys in the range lo (inclusive)
+// to hi (exclusive) in ascending key order. A nil lo or hi leaves the range unbounded.
+func (t *PersistentTree) Range(lo, hi []byte) iter.Seq2[[]byte, []byte] {
+	return t.seq(lo, hi, true)
+}
+
+//Generate a sequence over the range. As nodes are never changed the sequence
+// continues over the tree as it was when the sequence began, even if the tree
+// is changed while iterating.
+func (t *PersistentTree) seq(start, end []byte, ascending bool) iter.Seq2[[]byte, []byte] {
+	trunk := t.trunk
+	return func(yield func(key, value []byte) bool) {
+		trunk.iterate(start, end, ascending, yield)
+	}
+}
+
+/////////////////////////////
+// Node Functions
+/////////////////////////////
+
+//Generate a new node from its record and children,
+// calculating its height, size, and hash with the tree's hashing scheme
+func (t *PersistentTree) newNode(key, value []byte, leftNode, rightNode *persistentNode) *persistentNode {
+
+	height := leftNode.getHeight()
+	if rightNode.getHeight() > height {
+		height = rightNode.getHeight()
+	}
+	height++
+
+	return &persistentNode{
+		key:       key,
+		value:     value,
+		height:    height,
+		size:      leftNode.getSize() + rightNode.getSize() + 1,
+		hash:      t.config.hashNode(key, value, height, leftNode.getHash(), rightNode.getHash()),
+		leftNode:  leftNode,
+		rightNode: rightNode,
+	}
+}
+
+//Generate a new node as newNode does, rotating it if it would be unbalanced
+// with the same rotations used by AVLTree
+func (t *PersistentTree) newBalancedNode(key, value []byte, leftNode, rightNode *persistentNode) *persistentNode {
+
+	switch bal := rightNode.getHeight() - leftNode.getHeight(); {
+	case bal > 1:
+		if rightNode.getBalance() < 0 { //Right Left Rotation
+			rightNode = t.rotate(rightNode.key, rightNode.value, rightNode.leftNode, rightNode.rightNode, false)
+		}
+		return t.rotate(key, value, leftNode, rightNode, true)
+	case bal < -1:
+		if leftNode.getBalance() > 0 { //Left Right Rotation
+			leftNode = t.rotate(leftNode.key, leftNode.value, leftNode.leftNode, leftNode.rightNode, true)
+		}
+		return t.rotate(key, value, leftNode, rightNode, false)
+	}
+
+	return t.newNode(key, value, leftNode, rightNode)
+}
+
+//Generate the rotation of a node with the record and children,
+// if leftRotation is true this will be a left rotation
+// otherwise function will perform a right rotation.
+func (t *PersistentTree) rotate(key, value []byte, leftNode, rightNode *persistentNode, leftRotation bool) *persistentNode {
+	if leftRotation {
+		nodeDown := t.newNode(key, value, leftNode, rightNode.leftNode)
+		return t.newNode(rightNode.key, rightNode.value, nodeDown, rightNode.rightNode)
+	}
+	nodeDown := t.newNode(key, value, leftNode.rightNode, rightNode)
+	return t.newNode(leftNode.key, leftNode.value, leftNode.leftNode, nodeDown)
+}
+
+//Returns the copy of the subtree with the key-value added
+func (t *PersistentTree) add(n *persistentNode, key, value []byte) (*persistentNode, error) {
+	if n == nil {
+		return t.newNode(key, value, nil, nil), nil
+	}
+
+	switch bytes.Compare(key, n.key) {
+	case -1:
+		leftNode, err := t.add(n.leftNode, key, value)
+		if err != nil {
+			return nil, err
+		}
+		return t.newBalancedNode(n.key, n.value, leftNode, n.rightNode), nil
+	case 1:
+		rightNode, err := t.add(n.rightNode, key, value)
+		if err != nil {
+			return nil, err
+		}
+		return t.newBalancedNode(n.key, n.value, n.leftNode, rightNode), nil
+	}
+
+	return nil, errDupVal
+}
+
+//Returns the copy of the subtree with the value updated
+func (t *PersistentTree) update(n *persistentNode, key, value []byte) (*persistentNode, error) {
+	if n == nil {
+		return nil, errBadKey
+	}
+
+	switch bytes.Compare(key, n.key) {
+	case -1:
+		leftNode, err := t.update(n.leftNode, key, value)
+		if err != nil {
+			return nil, err
+		}
+		return t.newNode(n.key, n.value, leftNode, n.rightNode), nil
+	case 1:
+		rightNode, err := t.update(n.rightNode, key, value)
+		if err != nil {
+			return nil, err
+		}
+		return t.newNode(n.key, n.value, n.leftNode, rightNode), nil
+	}
+
+	return t.newNode(n.key, value, n.leftNode, n.rightNode), nil
+}
+
+//Returns the copy of the subtree with the key removed. As with AVLTree a node
+// with two children is replaced by the least key of its right subtree, unless
+// its left subtree is taller, in which case the greatest key of its left subtree.
+func (t *PersistentTree) remove(n *persistentNode, key []byte) (*persistentNode, error) {
+	if n == nil {
+		return nil, errBadKey
+	}
+
+	switch bytes.Compare(key, n.key) {
+	case -1:
+		leftNode, err := t.remove(n.leftNode, key)
+		if err != nil {
+			return nil, err
+		}
+		return t.newBalancedNode(n.key, n.value, leftNode, n.rightNode), nil
+	case 1:
+		rightNode, err := t.remove(n.rightNode, key)
+		if err != nil {
+			return nil, err
+		}
+		return t.newBalancedNode(n.key, n.value, n.leftNode, rightNode), nil
+	}
+
+	switch {
+	case n.leftNode == nil:
+		return n.rightNode, nil
+	case n.rightNode == nil:
+		return n.leftNode, nil
+	case n.getBalance() >= 0:
+		replaceFromNode := n.rightNode.findMin()
+		rightNode, _ := t.remove(n.rightNode, replaceFromNode.key)
+		return t.newBalancedNode(replaceFromNode.key, replaceFromNode.value, n.leftNode, rightNode), nil
+	default:
+		replaceFromNode := n.leftNode.findMax()
+		leftNode, _ := t.remove(n.leftNode, replaceFromNode.key)
+		return t.newBalancedNode(replaceFromNode.key, replaceFromNode.value, leftNode, n.rightNode), nil
+	}
+}
+
+//Attributes of a node, where a nil node is an empty subtree
+func (n *persistentNode) getHeight() int {
+	if n == nil {
+		return -1
+	}
+	return n.height
+}
+
+func (n *persistentNode) getSize() int {
+	if n == nil {
+		return 0
+	}
+	return n.size
+}
+
+func (n *persistentNode) getHash() []byte {
+	if n == nil {
+		return nil
+	}
+	return n.hash
+}
+
+func (n *persistentNode) getBalance() int {
+	return n.rightNode.getHeight() - n.leftNode.getHeight()
+}
+
+func (n *persistentNode) findMin() *persistentNode {
+	if n.leftNode == nil {
+		return n
+	}
+	return n.leftNode.findMin()
+}
+
+func (n *persistentNode) findMax() *persistentNode {
+	if n.rightNode == nil {
+		return n
+	}
+	return n.rightNode.findMax()
+}
+
+//Recursively yield the key-value pairs of the subtree within the range start (inclusive)
+// to end (exclusive), returning false once yield has returned false
+func (n *persistentNode) iterate(start, end []byte, ascending bool, yield func(key, value []byte) bool) bool {
+	if n == nil {
+		return true
+	}
+
+	afterStart := start == nil || bytes.Compare(n.key, start) >= 0
+	beforeEnd := end == nil || bytes.Compare(n.key, end) < 0
+
+	first, second := n.leftNode, n.rightNode
+	firstInRange, secondInRange := afterStart, beforeEnd
+	if !ascending {
+		first, second = n.rightNode, n.leftNode
+		firstInRange, secondInRange = beforeEnd, afterStart
+	}
+
+	if firstInRange && !first.iterate(start, end, ascending, yield) {
+		return false
+	}
+	if afterStart && beforeEnd && !yield(n.key, n.value) {
+		return false
+	}
+	if secondInRange && !second.iterate(start, end, ascending, yield) {
+		return false
+	}
+	return true
+}
+
+//Recursively print the structure downstream of a node, with the parent's key passed in
+func (n *persistentNode) outputStructure(parKey []byte) (out string) {
+	if n == nil {
+		return ""
+	}
+
+	parkey, leftkey, rightkey := "nil", "nil", "nil"
+
+	if parKey != nil {
+		parkey = string(parKey)
+	}
+
+	if n.leftNode != nil {
+		out += n.leftNode.outputStructure(n.key)
+		leftkey = string(n.leftNode.key)
+	}
+
+	if n.rightNode != nil {
+		out += n.rightNode.outputStructure(n.key)
+		rightkey = string(n.rightNode.key)
+	}
+
+	out += "key: " + string(n.key) +
+		" value: " + string(n.value) +
+		" parent: " + parkey +
+		" leftChild: " + leftkey +
+		" rightChild: " + rightkey +
+		"\n"
+
+	return
+}
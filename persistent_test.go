@@ -0,0 +1,189 @@
+package AVL_Tree
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestPersistentTree(t *testing.T) {
+
+	//The persistent tree to be tested, and an AVLTree with the same changes
+	tr := NewPersistentTree()
+	avlTr := NewAVLTree()
+
+	if _, err := tr.GetHash(); err != errEmptyTree {
+		t.Errorf("expected an empty tree error, found %v", err)
+	}
+
+	//Test that the trees hold the same records, shape, and hash
+	checkTree := func(op string) {
+		hash, _ := tr.GetHash()
+		expdHash, _ := avlTr.GetHash()
+		if !bytes.Equal(hash, expdHash) {
+			t.Fatalf("bad hash after %v, expected %x found %x", op, expdHash, hash)
+		}
+		if tr.TreeStructure() != avlTr.TreeStructure() {
+			t.Fatalf("bad structure after %v, expected\n%v\nfound\n%v", op, avlTr.TreeStructure(), tr.TreeStructure())
+		}
+		if tr.Size() != avlTr.Size() {
+			t.Fatalf("bad size after %v, expected %v found %v", op, avlTr.Size(), tr.Size())
+		}
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		key := []byte(fmt.Sprintf("k%02d", rnd.Intn(64)))
+		value := []byte(fmt.Sprintf("v%d", i))
+
+		var op string
+		var err, expdErr error
+		switch rnd.Intn(4) {
+		case 0:
+			op = "add " + string(key)
+			err, expdErr = tr.Add(key, value), avlTr.Add(key, value)
+		case 1:
+			op = "set " + string(key)
+			err, expdErr = tr.Set(key, value), avlTr.Set(key, value)
+		case 2:
+			op = "update " + string(key)
+			err, expdErr = tr.Update(key, value), avlTr.Update(key, value)
+		case 3:
+			op = "remove " + string(key)
+			err, expdErr = tr.Remove(key), avlTr.Remove(key)
+		}
+		if err != expdErr {
+			t.Fatalf("bad error for %v, expected %v found %v", op, expdErr, err)
+		}
+
+		value, err = tr.Get(key)
+		expdValue, expdErr := avlTr.Get(key)
+		if !bytes.Equal(value, expdValue) || err != expdErr {
+			t.Fatalf("bad value after %v, expected %s %v found %s %v", op, expdValue, expdErr, value, err)
+		}
+
+		checkTree(op)
+	}
+}
+
+func TestPersistentTreeSnapshot(t *testing.T) {
+
+	tr := NewPersistentTree()
+
+	printErr := func(err error) {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	for i := 0; i < 32; i++ {
+		key := fmt.Sprintf("k%02d", i)
+		printErr(tr.Add([]byte(key), []byte("a"+key)))
+	}
+
+	snapshot := tr.Snapshot()
+	snapshotHash, _ := snapshot.GetHash()
+	if snapshot.trunk != tr.trunk {
+		t.Errorf("expected the snapshot to share the trunk")
+	}
+
+	//Changing the greatest key only copies the path down the right of the tree
+	printErr(tr.Update([]byte("k31"), []byte("changed")))
+	if snapshot.trunk.leftNode != tr.trunk.leftNode {
+		t.Errorf("expected the unchanged left subtree to be shared")
+	}
+	if snapshot.trunk.rightNode == tr.trunk.rightNode {
+		t.Errorf("expected the changed right subtree to be copied")
+	}
+
+	//Test that the snapshot is unaffected by further changes
+	for i := 0; i < 32; i += 2 {
+		key := fmt.Sprintf("k%02d", i)
+		printErr(tr.Remove([]byte(key)))
+	}
+	printErr(tr.Add([]byte("new"), []byte("value")))
+
+	hash, _ := snapshot.GetHash()
+	if !bytes.Equal(hash, snapshotHash) {
+		t.Errorf("bad snapshot hash, expected %x found %x", snapshotHash, hash)
+	}
+	for i := 0; i < 32; i++ {
+		key := fmt.Sprintf("k%02d", i)
+		value, err := snapshot.Get([]byte(key))
+		if err != nil || string(value) != "a"+key {
+			t.Errorf("bad snapshot value for %v, found %v %v", key, string(value), err)
+		}
+	}
+	if _, err := snapshot.Get([]byte("new")); err != errBadKey {
+		t.Errorf("expected a bad key error from the snapshot, found %v", err)
+	}
+	if snapshot.Size() != 32 || tr.Size() != 17 {
+		t.Errorf("bad sizes, expected 32 and 17 found %v and %v", snapshot.Size(), tr.Size())
+	}
+
+	//Changes to the snapshot do not affect the tree either
+	treeHash, _ := tr.GetHash()
+	printErr(snapshot.Set([]byte("k00"), []byte("changed")))
+	hash, _ = tr.GetHash()
+	if !bytes.Equal(hash, treeHash) {
+		t.Errorf("bad tree hash after changing the snapshot, expected %x found %x", treeHash, hash)
+	}
+}
+
+func TestPersistentTreeSeq(t *testing.T) {
+
+	//The persistent tree to be tested, and an AVLTree with the same records
+	tr := NewPersistentTree()
+	avlTr := NewAVLTree()
+
+	for i := 0; i < 64; i += 2 {
+		key := []byte(fmt.Sprintf("k%02d", i))
+		tr.Add(key, key)
+		avlTr.Add(key, key)
+	}
+
+	//Test that the sequences match those of AVLTree, including when breaking
+	seqTest := func(name string, seq, expdSeq func(yield func(key, value []byte) bool), limit int) {
+		var keys, expdKeys []string
+		for key := range seq {
+			if keys = append(keys, string(key)); len(keys) == limit {
+				break
+			}
+		}
+		for key := range expdSeq {
+			if expdKeys = append(expdKeys, string(key)); len(expdKeys) == limit {
+				break
+			}
+		}
+		if fmt.Sprint(keys) != fmt.Sprint(expdKeys) {
+			t.Errorf("bad keys for %v, expected %v found %v", name, expdKeys, keys)
+		}
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		var lo, hi []byte
+		if rnd.Intn(4) != 0 {
+			lo = []byte(fmt.Sprintf("k%02d", rnd.Intn(70)))
+		}
+		if rnd.Intn(4) != 0 {
+			hi = []byte(fmt.Sprintf("k%02d", rnd.Intn(70)))
+		}
+		limit := rnd.Intn(40)
+
+		seqTest(fmt.Sprintf("Range(%s, %s)", lo, hi), tr.Range(lo, hi), avlTr.Range(lo, hi), limit)
+		seqTest("All", tr.All(), avlTr.All(), limit)
+		seqTest("Backward", tr.Backward(), avlTr.Backward(), limit)
+	}
+
+	//Changing the tree while iterating continues over the tree as it was
+	var keys []string
+	for key := range tr.All() {
+		keys = append(keys, string(key))
+		tr.Remove(key)
+	}
+	if len(keys) != 32 || tr.Size() != 0 {
+		t.Errorf("bad iteration while changing, expected 32 keys found %v", len(keys))
+	}
+}
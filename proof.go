@@ -0,0 +1,220 @@
+package AVL_Tree
+
+import (
+	"bytes"
+	"errors"
+)
+
+//errors used for proofs
+var errUnprovable error = errors.New("Proofs cannot be made with the legacy merkle hash")
+var errBadProof error = errors.New("Proof does not match the merkle root hash")
+var errKeyExists error = errors.New("Key exists")
+
+//Proof of existence or absence for a key against a merkle root hash.
+// The path lists the parent records and sibling hashes from the proven
+// node (or placeholder for absence proofs) up to the trunk.
+type Proof struct {
+	Height    int    //height of the proven node
+	LeftHash  []byte //hash of the left child of the proven node, nil if it is a placeholder
+	RightHash []byte //hash of the right child of the proven node, nil if it is a placeholder
+	Path      []ProofStep
+}
+
+//A single step of a proof, moving from a child up to its parent
+type ProofStep struct {
+	Key         []byte //key held by the parent
+	Value       []byte //value held by the parent
+	Height      int    //height of the parent
+	IsLeftChild bool   //is the child on the proof path the left child of the parent
+	SiblingHash []byte //hash of the other child of the parent, nil if it is a placeholder
+}
+
+//Get a value from the tree from an existing key along with a proof
+// that the key-value pair is held under the tree's merkle root hash
+func (t *AVLTree) GetWithProof(key []byte) (value []byte, proof *Proof, err error) {
+	if err = t.storeErr(); err != nil {
+		return
+	}
+	defer t.finishStore(&err)
+
+	if t.trunk.isPlaceholder() {
+		err = errEmptyTree
+		return
+	}
+
+	if !t.config.commitsBranches() {
+		err = errUnprovable
+		return
+	}
+
+	t.trunk.rehash(t)
+	matchNode := t.trunk.findNode(key)
+
+	if matchNode.isPlaceholder() {
+		err = errBadKey
+		return
+	}
+
+	value = matchNode.value
+	proof = matchNode.proofPath()
+	proof.Height = matchNode.height
+	proof.LeftHash = matchNode.leftNode.hash
+	proof.RightHash = matchNode.rightNode.hash
+
+	return
+}
+
+//Get a proof that a key is not held under the tree's merkle root hash.
+// The proof path leads to the placeholder where the key would be added,
+// the in-order neighbours of the key are held along this path.
+func (t *AVLTree) GetAbsenceProof(key []byte) (proof *Proof, err error) {
+	if err = t.storeErr(); err != nil {
+		return
+	}
+	defer t.finishStore(&err)
+
+	if t.trunk.isPlaceholder() {
+		err = errEmptyTree
+		return
+	}
+
+	if !t.config.commitsBranches() {
+		err = errUnprovable
+		return
+	}
+
+	t.trunk.rehash(t)
+	insertPH := t.trunk.findNode(key)
+
+	if !insertPH.isPlaceholder() {
+		err = errKeyExists
+		return
+	}
+
+	proof = insertPH.proofPath()
+
+	return
+}
+
+//Generate the proof path from the current node up to the trunk
+func (n *node) proofPath() *Proof {
+
+	proof := &Proof{}
+
+	for child := n; !child.isTrunk(); child = child.parNode {
+		step := ProofStep{
+			Key:         child.parNode.key,
+			Value:       child.parNode.value,
+			Height:      child.parNode.height,
+			IsLeftChild: child.isLeftChild(),
+		}
+
+		if step.IsLeftChild {
+			step.SiblingHash = child.parNode.rightNode.hash
+		} else {
+			step.SiblingHash = child.parNode.leftNode.hash
+		}
+
+		proof.Path = append(proof.Path, step)
+	}
+
+	return proof
+}
+
+//Verify that a key-value pair is held under the merkle root hash
+// by recomputing the root hash from the proof. Options must be passed in
+// to match the hashing configuration of the tree which generated the proof,
+// records cannot be proven with the legacy scheme.
+func Verify(rootHash, key, value []byte, proof *Proof, opts ...Option) error {
+	c := newConfig(opts)
+
+	if !c.commitsBranches() {
+		return errUnprovable
+	}
+
+	if proof == nil || key == nil || !proof.followsKey(key) {
+		return errBadProof
+	}
+
+	hash := c.hashNode(key, value, proof.Height, proof.LeftHash, proof.RightHash)
+	if !bytes.Equal(proof.computeRoot(&c, hash), rootHash) {
+		return errBadProof
+	}
+
+	return nil
+}
+
+//Verify that a key is not held under the merkle root hash. The proof must
+// lead to a placeholder along the path that a search for the key would take,
+// which shows that the in-order neighbours of the key are adjacent.
+// Options must be passed in to match the hashing configuration of the tree
+// which generated the proof, absence cannot be proven with the legacy scheme.
+func VerifyAbsence(rootHash, key []byte, proof *Proof, opts ...Option) error {
+	c := newConfig(opts)
+
+	if !c.commitsBranches() {
+		return errUnprovable
+	}
+
+	if proof == nil || key == nil || len(proof.Path) == 0 || !proof.followsKey(key) {
+		return errBadProof
+	}
+
+	//The path begins with a placeholder which holds no hash
+	if !bytes.Equal(proof.computeRoot(&c, nil), rootHash) {
+		return errBadProof
+	}
+
+	return nil
+}
+
+//Retrieve the keys of the in-order neighbours of an absent key from its proof.
+// The predecessor is nil if the key is below the minimum key of the tree
+// and the successor is nil if the key is above the maximum key of the tree.
+func (p *Proof) Neighbours() (predecessor, successor []byte) {
+
+	//The closest parent to the placeholder on each side is the neighbour
+	for _, step := range p.Path {
+		switch {
+		case step.IsLeftChild && successor == nil:
+			successor = step.Key
+		case !step.IsLeftChild && predecessor == nil:
+			predecessor = step.Key
+		}
+	}
+
+	return
+}
+
+//Does the proof path follow the path that a search for the key would take?
+func (p *Proof) followsKey(key []byte) bool {
+	for _, step := range p.Path {
+
+		//Compare(a,b) will be 0 if a==b, -1 if a < b, and +1 if a > b
+		switch bytes.Compare(key, step.Key) {
+		case 0:
+			return false
+		case -1:
+			if !step.IsLeftChild {
+				return false
+			}
+		case 1:
+			if step.IsLeftChild {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+//Compute the root hash by hashing upwards from the hash of the proven node
+func (p *Proof) computeRoot(c *config, hash []byte) []byte {
+	for _, step := range p.Path {
+		if step.IsLeftChild {
+			hash = c.hashNode(step.Key, step.Value, step.Height, hash, step.SiblingHash)
+		} else {
+			hash = c.hashNode(step.Key, step.Value, step.Height, step.SiblingHash, hash)
+		}
+	}
+	return hash
+}
@@ -0,0 +1,317 @@
+package AVL_Tree
+
+import (
+	"testing"
+)
+
+func TestProof(t *testing.T) {
+
+	//The AVLTree to be tested with
+	tr := NewAVLTree()
+
+	printErr := func(err error) {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	//Test retrieving and verifying a proof for a key.
+	// The parameter expectedErr specifies the error expected
+	// from retrieving the proof (used for testing missing keys)
+	proofTest := func(key, expectedVal string, expectedErr error) {
+		rootHash, _ := tr.GetHash()
+
+		value, proof, err := tr.GetWithProof([]byte(key))
+		if err != expectedErr {
+			t.Errorf("bad error for %v, expected %v found %v", key, expectedErr, err)
+			return
+		}
+		if expectedErr != nil {
+			return
+		}
+
+		if string(value) != expectedVal {
+			t.Errorf("bad expected %v recieved %v ", expectedVal, string(value))
+		}
+
+		if err := Verify(rootHash, []byte(key), value, proof); err != nil {
+			t.Errorf("bad proof for %v: %v", key, err)
+		}
+
+		//A proof must not verify a different value, key, or root
+		if Verify(rootHash, []byte(key), []byte("bad"), proof) == nil {
+			t.Errorf("expected proof for %v to fail with a bad value", key)
+		}
+		if Verify(rootHash, []byte("bad"), value, proof) == nil {
+			t.Errorf("expected proof for %v to fail with a bad key", key)
+		}
+		if Verify([]byte("bad"), []byte(key), value, proof) == nil {
+			t.Errorf("expected proof for %v to fail with a bad root hash", key)
+		}
+	}
+
+	//Test proofs for an empty tree
+	proofTest("a", "", errEmptyTree)
+
+	//Test proofs for a tree with a single child branch
+	// a
+	//  \
+	//   b
+	printErr(tr.Add([]byte("a"), []byte("vA")))
+	proofTest("a", "vA", nil)
+	printErr(tr.Add([]byte("b"), []byte("vB")))
+	proofTest("a", "vA", nil)
+	proofTest("b", "vB", nil)
+
+	//Test proofs for a balanced tree
+	//      d
+	//    /   \
+	//   b     f
+	//  / \   / \
+	// a   c e   g
+	printErr(tr.Add([]byte("c"), []byte("vC")))
+	printErr(tr.Add([]byte("d"), []byte("vD")))
+	printErr(tr.Add([]byte("e"), []byte("vE")))
+	printErr(tr.Add([]byte("f"), []byte("vF")))
+	printErr(tr.Add([]byte("g"), []byte("vG")))
+	t.Log(tr.TreeStructure())
+
+	proofTest("a", "vA", nil)
+	proofTest("c", "vC", nil)
+	proofTest("e", "vE", nil)
+	proofTest("g", "vG", nil)
+	proofTest("b", "vB", nil)
+	proofTest("d", "vD", nil)
+	proofTest("f", "vF", nil)
+	proofTest("z", "", errBadKey)
+
+	//A proof for one key must not verify another record
+	rootHash, _ := tr.GetHash()
+	_, proof, err := tr.GetWithProof([]byte("a"))
+	printErr(err)
+	if Verify(rootHash, []byte("c"), []byte("vC"), proof) == nil {
+		t.Errorf("expected proof for a to fail for key c")
+	}
+}
+
+func TestAbsenceProof(t *testing.T) {
+
+	//The AVLTree to be tested with
+	tr := NewAVLTree()
+
+	printErr := func(err error) {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	//Test retrieving and verifying an absence proof for a key
+	// along with the expected neighbours of the key
+	absenceTest := func(key, expdPred, expdSucc string) {
+		rootHash, _ := tr.GetHash()
+
+		proof, err := tr.GetAbsenceProof([]byte(key))
+		if err != nil {
+			t.Errorf("bad absence proof for %v: %v", key, err)
+			return
+		}
+
+		if err := VerifyAbsence(rootHash, []byte(key), proof); err != nil {
+			t.Errorf("bad absence proof for %v: %v", key, err)
+		}
+
+		pred, succ := proof.Neighbours()
+		if string(pred) != expdPred || string(succ) != expdSucc {
+			t.Errorf("bad neighbours for %v, expected %v and %v found %v and %v",
+				key, expdPred, expdSucc, string(pred), string(succ))
+		}
+
+		//The proof must not verify against a different root
+		// or for keys which are held within the tree
+		if VerifyAbsence([]byte("bad"), []byte(key), proof) == nil {
+			t.Errorf("expected absence proof for %v to fail with a bad root hash", key)
+		}
+		for _, neighbour := range [][]byte{pred, succ} {
+			if neighbour != nil && VerifyAbsence(rootHash, neighbour, proof) == nil {
+				t.Errorf("expected absence proof for %v to fail for key %v", key, string(neighbour))
+			}
+		}
+	}
+
+	//Test absence proofs for an empty tree
+	_, err := tr.GetAbsenceProof([]byte("a"))
+	if err != errEmptyTree {
+		t.Errorf("expected an empty tree error, found %v", err)
+	}
+
+	//Test absence proofs for the tree
+	//      d
+	//    /   \
+	//   b     f
+	//  / \   / \
+	// a   c e   g
+	for _, key := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		printErr(tr.Add([]byte(key), []byte("v"+key)))
+	}
+
+	absenceTest("0", "", "a")
+	absenceTest("aa", "a", "b")
+	absenceTest("bb", "b", "c")
+	absenceTest("cc", "c", "d")
+	absenceTest("dd", "d", "e")
+	absenceTest("ff", "f", "g")
+	absenceTest("z", "g", "")
+
+	//Test absence proofs for existing keys
+	_, err = tr.GetAbsenceProof([]byte("d"))
+	if err != errKeyExists {
+		t.Errorf("expected a key exists error, found %v", err)
+	}
+}
+
+func TestForgedProof(t *testing.T) {
+
+	//The AVLTree to be tested with
+	//      d
+	//    /   \
+	//   b     f
+	//  / \   / \
+	// a   c e   g
+	tr := NewAVLTree()
+	for _, key := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		if err := tr.Add([]byte(key), []byte("v"+key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rootHash, _ := tr.GetHash()
+
+	//A branch node must not be provable as a leaf node holding the hashes
+	// of its children as its key and value. The keys along the path are
+	// replaced so that the path follows the search path of the forged key.
+	for _, branchKey := range []string{"b", "d", "f"} {
+		key, value, proof := forgeProof(&tr, branchKey)
+		if !proof.followsKey(key) {
+			t.Fatalf("expected forged proof for %v to follow the forged key", branchKey)
+		}
+		if Verify(rootHash, key, value, proof) == nil {
+			t.Errorf("expected forged proof for %v to be rejected", branchKey)
+		}
+	}
+}
+
+//Forge a proof which presents a branch node as a leaf node
+// with the hashes of its children held as the key and value
+func forgeProof(tr *AVLTree, branchKey string) (key, value []byte, proof *Proof) {
+	branch := tr.trunk.findNode([]byte(branchKey))
+	key = branch.leftNode.hash
+	value = branch.rightNode.hash
+	proof = branch.proofPath()
+
+	//A longer key with the forged key as its prefix is greater than
+	// the forged key, and the forged key without its last byte is lesser
+	for i, step := range proof.Path {
+		if step.IsLeftChild {
+			proof.Path[i].Key = append(append([]byte{}, key...), 0x00)
+		} else {
+			proof.Path[i].Key = key[:len(key)-1]
+		}
+	}
+
+	return
+}
+
+func TestLegacyProof(t *testing.T) {
+
+	//The AVLTree to be tested with
+	//      d
+	//    /   \
+	//   b     f
+	//  / \   / \
+	// a   c e   g
+	tr := NewAVLTree(WithHashScheme(HashSchemeLegacy))
+	for _, key := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		if err := tr.Add([]byte(key), []byte("v"+key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rootHash, _ := tr.GetHash()
+	legacy := WithHashScheme(HashSchemeLegacy)
+
+	//Test that no proofs can be retrieved from a tree using the legacy scheme
+	if _, _, err := tr.GetWithProof([]byte("a")); err != errUnprovable {
+		t.Errorf("expected an unprovable error, found %v", err)
+	}
+	if _, err := tr.GetAbsenceProof([]byte("aa")); err != errUnprovable {
+		t.Errorf("expected an unprovable error, found %v", err)
+	}
+	if _, _, _, err := tr.GetRangeWithProof(nil, nil); err != errUnprovable {
+		t.Errorf("expected an unprovable error, found %v", err)
+	}
+	if _, _, err := tr.GetMultiWithProof([][]byte{[]byte("a")}); err != errUnprovable {
+		t.Errorf("expected an unprovable error, found %v", err)
+	}
+
+	//Test that a forged proof matches the legacy root hash but is refused.
+	// The leaf hash of the forged record is the hash of the branch node,
+	// so the recomputed root is the root hash of the tree.
+	c := newConfig([]Option{legacy})
+	for _, branchKey := range []string{"b", "d", "f"} {
+		key, value, proof := forgeProof(&tr, branchKey)
+		forgedRoot := proof.computeRoot(&c, c.hashNode(key, value, 0, nil, nil))
+		if string(forgedRoot) != string(rootHash) {
+			t.Fatalf("expected forged proof for %v to match the legacy root hash", branchKey)
+		}
+		if err := Verify(rootHash, key, value, proof, legacy); err != errUnprovable {
+			t.Errorf("expected forged proof for %v to be unprovable, found %v", branchKey, err)
+		}
+
+		keys, values, multiProof := forgeMultiProof(&tr, branchKey)
+		if string(multiProof.Root.computeHash(&c)) != string(rootHash) {
+			t.Fatalf("expected forged multiproof for %v to match the legacy root hash", branchKey)
+		}
+		if err := VerifyMulti(rootHash, keys, values, multiProof, legacy); err != errUnprovable {
+			t.Errorf("expected forged multiproof for %v to be unprovable, found %v", branchKey, err)
+		}
+	}
+
+	//Test that proofs from a domain separated tree are refused by legacy verification
+	dsTr := NewAVLTree()
+	for _, key := range []string{"a", "b", "c"} {
+		if err := dsTr.Add([]byte(key), []byte("v"+key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dsRootHash, _ := dsTr.GetHash()
+
+	value, proof, err := dsTr.GetWithProof([]byte("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(dsRootHash, []byte("a"), value, proof, legacy); err != errUnprovable {
+		t.Errorf("expected an unprovable error, found %v", err)
+	}
+
+	proof, err = dsTr.GetAbsenceProof([]byte("aa"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyAbsence(dsRootHash, []byte("aa"), proof, legacy); err != errUnprovable {
+		t.Errorf("expected an unprovable error, found %v", err)
+	}
+
+	keys, values, rangeProof, err := dsTr.GetRangeWithProof(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyRange(dsRootHash, nil, nil, keys, values, rangeProof, legacy); err != errUnprovable {
+		t.Errorf("expected an unprovable error, found %v", err)
+	}
+
+	values, multiProof, err := dsTr.GetMultiWithProof(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyMulti(dsRootHash, keys, values, multiProof, legacy); err != errUnprovable {
+		t.Errorf("expected an unprovable error, found %v", err)
+	}
+}
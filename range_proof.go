@@ -0,0 +1,175 @@
+package AVL_Tree
+
+import (
+	"bytes"
+)
+
+//Proof of all the key-value pairs held within a key range against a merkle root hash.
+// The proof holds the tree pruned down to the nodes which may hold keys within the range,
+// all other subtrees are replaced by their hash.
+type RangeProof struct {
+	Root *ProofNode
+}
+
+//A node of a pruned tree proof. Records within the proven parts of the tree
+// are held with their children, pruned subtrees only hold their hash,
+// and nil nodes represent placeholders.
+type ProofNode struct {
+	Key    []byte
+	Value  []byte
+	Height int
+	Hash   []byte //hash of a pruned subtree, nil for nodes holding a record
+	Left   *ProofNode
+	Right  *ProofNode
+}
+
+//Get all the key-value pairs with keys in the range start (inclusive) to end (exclusive)
+// along with a single proof for the range. A nil start or end leaves the range unbounded.
+func (t *AVLTree) GetRangeWithProof(start, end []byte) (keys, values [][]byte, proof *RangeProof, err error) {
+	if err = t.storeErr(); err != nil {
+		return
+	}
+	defer t.finishStore(&err)
+
+	if t.trunk.isPlaceholder() {
+		err = errEmptyTree
+		return
+	}
+
+	if !t.config.commitsBranches() {
+		err = errUnprovable
+		return
+	}
+
+	t.trunk.rehash(t)
+	proof = &RangeProof{
+		Root: t.trunk.rangeProofNode(nil, nil, start, end),
+	}
+
+	proof.Root.walkRange(start, end, func(key, value []byte) {
+		keys = append(keys, key)
+		values = append(values, value)
+	})
+
+	return
+}
+
+//Generate the range proof node for the current node whose subtree holds keys between
+// the exclusive bounds lo and hi. Subtrees which may hold keys within or at the end of
+// the range are expanded, this includes the search paths of both ends of the range.
+func (n *node) rangeProofNode(lo, hi, start, end []byte) *ProofNode {
+
+	if n.isPlaceholder() {
+		return nil
+	}
+
+	if !overlaps(lo, hi, start, end) {
+		return &ProofNode{Hash: n.hash}
+	}
+
+	return &ProofNode{
+		Key:    n.key,
+		Value:  n.value,
+		Height: n.height,
+		Left:   n.leftNode.rangeProofNode(lo, n.key, start, end),
+		Right:  n.rightNode.rangeProofNode(n.key, hi, start, end),
+	}
+}
+
+//Verify that the keys and values are all the key-value pairs held under the merkle root hash
+// within the range start (inclusive) to end (exclusive). Any omitted or injected
+// key-value pair within the range will cause the verification to fail.
+// Options must be passed in to match the hashing configuration of the tree
+// which generated the proof, ranges cannot be proven with the legacy scheme.
+func VerifyRange(rootHash, start, end []byte, keys, values [][]byte, proof *RangeProof, opts ...Option) error {
+	c := newConfig(opts)
+
+	if !c.commitsBranches() {
+		return errUnprovable
+	}
+
+	if proof == nil || proof.Root == nil || len(keys) != len(values) {
+		return errBadProof
+	}
+
+	//Verify the pruned tree is ordered and hides no keys within the range
+	if !proof.Root.isValidRange(nil, nil, start, end) {
+		return errBadProof
+	}
+
+	//Verify the records within the range are the expected key-value pairs
+	i := 0
+	matches := true
+	proof.Root.walkRange(start, end, func(key, value []byte) {
+		if i >= len(keys) || !bytes.Equal(key, keys[i]) || !bytes.Equal(value, values[i]) {
+			matches = false
+		}
+		i++
+	})
+	if !matches || i != len(keys) {
+		return errBadProof
+	}
+
+	if !bytes.Equal(proof.Root.computeHash(&c), rootHash) {
+		return errBadProof
+	}
+
+	return nil
+}
+
+//Is the proof subtree ordered within the exclusive bounds lo and hi,
+// with no pruned subtrees which may hold keys within the range?
+func (pn *ProofNode) isValidRange(lo, hi, start, end []byte) bool {
+
+	switch {
+	case pn == nil:
+		return true
+	case pn.Key == nil:
+		return pn.Hash != nil && !overlaps(lo, hi, start, end)
+	case lo != nil && bytes.Compare(pn.Key, lo) <= 0,
+		hi != nil && bytes.Compare(pn.Key, hi) >= 0:
+		return false
+	}
+
+	return pn.Left.isValidRange(lo, pn.Key, start, end) &&
+		pn.Right.isValidRange(pn.Key, hi, start, end)
+}
+
+//Walk through the records held by the proof subtree in order,
+// calling fn for each record within the range
+func (pn *ProofNode) walkRange(start, end []byte, fn func(key, value []byte)) {
+
+	if pn == nil || pn.Key == nil {
+		return
+	}
+
+	pn.Left.walkRange(start, end, fn)
+
+	if (start == nil || bytes.Compare(pn.Key, start) >= 0) &&
+		(end == nil || bytes.Compare(pn.Key, end) < 0) {
+		fn(pn.Key, pn.Value)
+	}
+
+	pn.Right.walkRange(start, end, fn)
+}
+
+//Recompute the hash of the proof subtree using the same rules as updateHash
+func (pn *ProofNode) computeHash(c *config) []byte {
+
+	switch {
+	case pn == nil:
+		return nil
+	case pn.Key == nil:
+		return pn.Hash
+	}
+
+	return c.hashNode(pn.Key, pn.Value, pn.Height, pn.Left.computeHash(c), pn.Right.computeHash(c))
+}
+
+//Could a subtree holding keys between the exclusive bounds lo and hi
+// hold keys within the range start to end, including the end key itself?
+// Nil bounds and range ends are unbounded.
+func overlaps(lo, hi, start, end []byte) bool {
+	return (start == nil || hi == nil || bytes.Compare(start, hi) < 0) &&
+		(end == nil || lo == nil || bytes.Compare(lo, end) < 0)
+}
@@ -0,0 +1,115 @@
+package AVL_Tree
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRangeProof(t *testing.T) {
+
+	//The AVLTree to be tested with
+	tr := NewAVLTree()
+
+	printErr := func(err error) {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	//Test proofs for an empty tree
+	_, _, _, err := tr.GetRangeWithProof(nil, nil)
+	if err != errEmptyTree {
+		t.Errorf("expected an empty tree error, found %v", err)
+	}
+
+	//Keys k00, k02 ... k38 so that there are gaps between every key
+	key := func(i int) []byte {
+		return []byte(fmt.Sprintf("k%02d", i))
+	}
+	for i := 0; i < 40; i += 2 {
+		printErr(tr.Add(key(i), []byte(fmt.Sprintf("v%02d", i))))
+	}
+	rootHash, _ := tr.GetHash()
+
+	//Test retrieving and verifying a range proof,
+	// the expected number of keys within the range is expdLen
+	rangeTest := func(start, end []byte, expdLen int) {
+		keys, values, proof, err := tr.GetRangeWithProof(start, end)
+		printErr(err)
+
+		if len(keys) != expdLen {
+			t.Errorf("bad number of keys for range %s to %s, expected %v found %v",
+				start, end, expdLen, len(keys))
+		}
+
+		if err := VerifyRange(rootHash, start, end, keys, values, proof); err != nil {
+			t.Errorf("bad range proof for %s to %s: %v", start, end, err)
+		}
+	}
+
+	rangeTest(nil, nil, 20)
+	rangeTest(key(0), key(40), 20)
+	rangeTest(key(10), key(20), 5)
+	rangeTest(key(11), key(21), 5)
+	rangeTest(key(10), key(11), 1)
+	rangeTest(key(11), key(12), 0)
+	rangeTest(nil, key(10), 5)
+	rangeTest(key(31), nil, 4)
+	rangeTest([]byte("a"), []byte("b"), 0)
+	rangeTest([]byte("z"), nil, 0)
+
+	//Test that tampered results and proofs fail verification
+	start, end := key(9), key(25)
+	keys, values, proof, err := tr.GetRangeWithProof(start, end)
+	printErr(err)
+
+	failTest := func(description string, keys, values [][]byte, proof *RangeProof) {
+		if VerifyRange(rootHash, start, end, keys, values, proof) == nil {
+			t.Errorf("expected range proof to fail with %v", description)
+		}
+	}
+
+	failTest("an omitted first key", keys[1:], values[1:], proof)
+	failTest("an omitted last key", keys[:len(keys)-1], values[:len(values)-1], proof)
+
+	injectedKeys := append([][]byte{key(11)}, keys...)
+	injectedValues := append([][]byte{[]byte("v11")}, values...)
+	failTest("an injected key", injectedKeys, injectedValues, proof)
+
+	changedValues := append([][]byte{}, values...)
+	changedValues[2] = []byte("bad")
+	failTest("a changed value", keys, changedValues, proof)
+
+	if VerifyRange(rootHash, key(7), end, keys, values, proof) == nil {
+		t.Errorf("expected range proof to fail for a wider range")
+	}
+
+	//Pruning a record within the range from the proof must fail,
+	// find the leftmost record held within the range and replace it with its hash
+	var pruneInRange func(pn *ProofNode) bool
+	pruneInRange = func(pn *ProofNode) bool {
+		if pn == nil || pn.Key == nil {
+			return false
+		}
+		if pruneInRange(pn.Left) {
+			return true
+		}
+		if pn.Left != nil && pn.Left.Key != nil && string(pn.Left.Key) >= string(start) {
+			pn.Left = &ProofNode{Hash: pn.Left.computeHash(&tr.config)}
+			return true
+		}
+		return pruneInRange(pn.Right)
+	}
+	if !pruneInRange(proof.Root) {
+		t.Fatalf("expected to find a record within the range to prune")
+	}
+	if string(proof.Root.computeHash(&tr.config)) != string(rootHash) {
+		t.Errorf("expected pruned proof to still hash to the root hash")
+	}
+	prunedKeys, prunedValues := [][]byte{}, [][]byte{}
+	proof.Root.walkRange(start, end, func(key, value []byte) {
+		prunedKeys = append(prunedKeys, key)
+		prunedValues = append(prunedValues, value)
+	})
+	failTest("a pruned record", prunedKeys, prunedValues, proof)
+}
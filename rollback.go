@@ -0,0 +1,122 @@
+package AVL_Tree
+
+import (
+	"errors"
+)
+
+//error used when rolling back a tree which has not been saved
+var errNoSavePoint error = errors.New("Tree has not been saved")
+
+//Journal of the changes to a tree since its save point, or since a transaction began.
+// Nodes are recorded before they are first changed, so only the nodes
+// changed since then are copied rather than the whole tree.
+type journal struct {
+	trunk   *node
+	nodes   map[*node]node //original fields of the changed nodes
+	orphans int            //number of orphaned records when the journal began
+	parent  *journal       //journal of the enclosing transaction or save point
+	tx      bool           //the journal belongs to a transaction
+}
+
+func newJournal(trunk *node) *journal {
+	return &journal{
+		trunk: trunk,
+		nodes: make(map[*node]node),
+	}
+}
+
+//Restore the nodes recorded by the journal, and the trunk
+func (t *AVLTree) restore(j *journal) {
+
+	s := t.store
+	if s == nil {
+		for n, fields := range j.nodes {
+			*n = fields
+		}
+		t.trunk = j.trunk
+		t.mutations++
+		return
+	}
+
+	for n, fields := range j.nodes {
+		*n = fields
+	}
+
+	//Nodes restored to unloaded nodes leave their loaded subtrees unreachable, so the cache
+	// is rebuilt from the saved nodes which remain loaded, keeping their order of use
+	loaded := make(map[*node]bool)
+	var walk func(n *node)
+	walk = func(n *node) {
+		if n.store != nil || n.key == nil { //unloaded or placeholder
+			return
+		}
+		if n.id != 0 {
+			loaded[n] = true
+		}
+		walk(n.leftNode)
+		walk(n.rightNode)
+	}
+	walk(j.trunk)
+
+	for n, elem := range s.resident {
+		if !loaded[n] {
+			s.lru.Remove(elem)
+			delete(s.resident, n)
+		}
+	}
+	for n := range loaded {
+		if _, ok := s.resident[n]; !ok {
+			s.track(n)
+		}
+	}
+
+	//Nodes which were changed hold their saved records again, and as all changes
+	// are restored this also recovers from a failure loading a node
+	s.orphans = s.orphans[:j.orphans]
+	s.err = nil
+
+	t.trunk = j.trunk
+	t.mutations++
+}
+
+//Record the node's fields, if it has not been recorded since the save point,
+// this must be called before any field of a node is changed.
+// The tree (tr) must be passed in in order to retrieve the journal.
+func (n *node) record(tr *AVLTree) {
+	if tr.journal == nil {
+		return
+	}
+	if _, ok := tr.journal.nodes[n]; !ok {
+		tr.journal.nodes[n] = *n
+	}
+}
+
+//Discard all changes since the tree was last saved, restoring the exact prior
+// structure and merkle hash. Trees with a node database are reset to the saved
+// tree, which also recovers a tree which has failed to load a node.
+// Generates an error if the tree has not been saved.
+func (t *AVLTree) Rollback() error {
+
+	if t.inTx() {
+		return errTxOpen
+	}
+
+	if t.store != nil {
+		if t.store.newTree {
+			return errNoSavePoint
+		}
+		t.store.reset()
+		t.trunk = t.store.newNodeRef(nil, t.store.meta.trunk)
+		t.mutations++
+		return nil
+	}
+
+	if t.journal == nil {
+		return errNoSavePoint
+	}
+
+	t.restore(t.journal)
+	t.journal = newJournal(t.trunk)
+
+	return nil
+}
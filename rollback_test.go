@@ -0,0 +1,139 @@
+package AVL_Tree
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestRollback(t *testing.T) {
+
+	//The AVLTree to be tested with
+	tr := NewAVLTree()
+
+	printErr := func(err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tr.Rollback(); err != errNoSavePoint {
+		t.Errorf("expected a no save point error, found %v", err)
+	}
+
+	//The serialized tree and structure at the save point, which
+	// together hold the exact shape, records, and hash of the tree
+	var savedBytes bytes.Buffer
+	var savedStructure string
+	save := func() {
+		_, err := tr.Save()
+		printErr(err)
+		savedBytes.Reset()
+		_, err = tr.WriteTo(&savedBytes)
+		printErr(err)
+		savedStructure = tr.TreeStructure()
+	}
+	save()
+
+	//Test that the tree is restored to the save point, including the parent links
+	checkRollback := func(op string) {
+		var treeBytes bytes.Buffer
+		_, err := tr.WriteTo(&treeBytes)
+		printErr(err)
+		if !bytes.Equal(treeBytes.Bytes(), savedBytes.Bytes()) || tr.TreeStructure() != savedStructure {
+			t.Fatalf("bad tree after %v, expected\n%v\nfound\n%v", op, savedStructure, tr.TreeStructure())
+		}
+
+		var checkParents func(n *node)
+		checkParents = func(n *node) {
+			if n.isPlaceholder() {
+				return
+			}
+			if n.leftNode.parNode != n || n.rightNode.parNode != n {
+				t.Fatalf("bad parent link for a child of %v after %v", string(n.key), op)
+			}
+			checkParents(n.leftNode)
+			checkParents(n.rightNode)
+		}
+		if !tr.trunk.isTrunk() {
+			t.Fatalf("bad trunk after %v", op)
+		}
+		checkParents(tr.trunk)
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		key := []byte(fmt.Sprintf("k%02d", rnd.Intn(64)))
+		value := []byte(fmt.Sprintf("v%d", i))
+
+		switch rnd.Intn(3) {
+		case 0:
+			tr.Remove(key)
+		default:
+			printErr(tr.Set(key, value))
+		}
+
+		switch rnd.Intn(20) {
+		case 0:
+			save()
+		case 1:
+			printErr(tr.Rollback())
+			checkRollback(fmt.Sprintf("rollback at %v", i))
+		}
+	}
+
+	//Rolling back an emptied tree
+	save()
+	for i := 0; i < 64; i++ {
+		tr.Remove([]byte(fmt.Sprintf("k%02d", i)))
+	}
+	printErr(tr.Rollback())
+	checkRollback("rollback of an emptied tree")
+}
+
+func TestRollbackNodeDB(t *testing.T) {
+
+	printErr := func(err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db := NewMemNodeDB()
+	tr := NewAVLTree(WithNodeDB(db), WithCacheSize(4))
+	if err := tr.Rollback(); err != errNoSavePoint {
+		t.Errorf("expected a no save point error, found %v", err)
+	}
+
+	for i := 0; i < 32; i++ {
+		printErr(tr.Add([]byte(fmt.Sprintf("k%02d", i)), []byte("a")))
+	}
+	savedHash, err := tr.Save()
+	printErr(err)
+	recordCount := db.Len()
+
+	for i := 0; i < 32; i += 2 {
+		printErr(tr.Update([]byte(fmt.Sprintf("k%02d", i)), []byte("b")))
+		printErr(tr.Remove([]byte(fmt.Sprintf("k%02d", i+1))))
+	}
+	printErr(tr.Rollback())
+
+	hash, _ := tr.GetHash()
+	if !bytes.Equal(hash, savedHash) {
+		t.Errorf("bad hash after rollback, expected %x found %x", savedHash, hash)
+	}
+	for i := 0; i < 32; i++ {
+		value, err := tr.Get([]byte(fmt.Sprintf("k%02d", i)))
+		if err != nil || string(value) != "a" {
+			t.Errorf("bad value for k%02d after rollback, found %v %v", i, string(value), err)
+		}
+	}
+
+	//The records orphaned by the discarded changes are kept for the saved tree
+	_, err = tr.Save()
+	printErr(err)
+	if db.Len() != recordCount {
+		t.Errorf("bad record count after rollback, expected %v found %v", recordCount, db.Len())
+	}
+}
@@ -0,0 +1,46 @@
+package AVL_Tree
+
+import (
+	"errors"
+	"iter"
+)
+
+//error used when a tree is modified while iterating
+var errTreeModified error = errors.New("Tree modified during iteration")
+
+//Returns a sequence over all key-value pairs in ascending key order
+func (t *AVLTree) All() iter.Seq2[[]byte, []byte] {
+	return t.seq(nil, nil, true)
+}
+
+//Returns a sequence over all key-value pairs in descending key order
+func (t *AVLTree) Backward() iter.Seq2[[]byte, []byte] {
+	return t.seq(nil, nil, false)
+}
+
+//Returns a sequence over the key-value pairs with keys in the range lo (inclusive)
+// to hi (exclusive) in ascending key order. A nil lo or hi leaves the range unbounded.
+func (t *AVLTree) Range(lo, hi []byte) iter.Seq2[[]byte, []byte] {
+	return t.seq(lo, hi, true)
+}
+
+//Generate a sequence from an iterator over the range. The tree must not be
+// modified while iterating, if it is, the sequence panics with errTreeModified
+// the next time it resumes rather than continuing over an altered tree.
+// If loading a node fails the sequence stops early and the failure is returned by Err.
+func (t *AVLTree) seq(start, end []byte, ascending bool) iter.Seq2[[]byte, []byte] {
+	return func(yield func(key, value []byte) bool) {
+		it := t.Iterator(start, end, ascending)
+		defer it.Close()
+
+		mutations := t.mutations
+		for ; it.Valid(); it.Next() {
+			if !yield(it.Key(), it.Value()) {
+				return
+			}
+			if t.mutations != mutations {
+				panic(errTreeModified)
+			}
+		}
+	}
+}
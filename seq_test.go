@@ -0,0 +1,69 @@
+package AVL_Tree
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSeq(t *testing.T) {
+
+	//The AVLTree to be tested with
+	tr := NewAVLTree()
+
+	printErr := func(err error) {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("k%d", i)
+		printErr(tr.Add([]byte(key), []byte("v"+key)))
+	}
+
+	//Test the keys produced by a sequence against the expected keys
+	seqTest := func(name string, seq func(yield func(key, value []byte) bool), expdKeys string) {
+		var keys []string
+		for key, value := range seq {
+			keys = append(keys, string(key))
+			if string(value) != "v"+string(key) {
+				t.Errorf("bad value for %s found %s", key, value)
+			}
+		}
+		if strings.Join(keys, ",") != expdKeys {
+			t.Errorf("bad keys for %v, expected %v found %v", name, expdKeys, keys)
+		}
+	}
+
+	seqTest("All", tr.All(), "k0,k1,k2,k3,k4,k5,k6,k7,k8,k9")
+	seqTest("Backward", tr.Backward(), "k9,k8,k7,k6,k5,k4,k3,k2,k1,k0")
+	seqTest("Range", tr.Range([]byte("k3"), []byte("k7")), "k3,k4,k5,k6")
+	seqTest("Range with open start", tr.Range(nil, []byte("k2")), "k0,k1")
+	seqTest("Range with open end", tr.Range([]byte("k75"), nil), "k8,k9")
+
+	//Test that breaking stops the traversal
+	var keys []string
+	for key := range tr.All() {
+		keys = append(keys, string(key))
+		if len(keys) == 3 {
+			break
+		}
+	}
+	if strings.Join(keys, ",") != "k0,k1,k2" {
+		t.Errorf("bad keys after breaking, expected k0,k1,k2 found %v", keys)
+	}
+
+	//Modifying the tree after breaking is allowed
+	printErr(tr.Set([]byte("k0"), []byte("vk0")))
+
+	//Test that modifying the tree while iterating panics
+	defer func() {
+		if r := recover(); r != errTreeModified {
+			t.Errorf("expected a tree modified panic, found %v", r)
+		}
+	}()
+	for key := range tr.All() {
+		printErr(tr.Remove(key))
+	}
+}
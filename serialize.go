@@ -0,0 +1,244 @@
+package AVL_Tree
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+	"math"
+)
+
+//errors used for serialization
+var errCorruptTree error = errors.New("Serialized tree is corrupt or truncated")
+var errBadFormat error = errors.New("Serialized tree format is not supported")
+var errRootMismatch error = errors.New("Serialized root hash does not match, the hashing options may differ")
+
+//Serialization format, all serialized trees begin with the magic bytes
+// followed by the format version.
+//
+// Version 1:
+//  - nodes in pre-order, each beginning with a flag byte which is 0 for a placeholder
+//    or 1 for a real node followed by its uvarint length-prefixed key and value
+//  - the uvarint length-prefixed root hash
+//  - the big-endian CRC-32 (Castagnoli) checksum of all preceding bytes
+var serialMagic = []byte("AVLT")
+
+const (
+	serialVersion byte = 1
+
+	serialPlaceholder byte = 0
+	serialNode        byte = 1
+
+	//AVL trees are far shallower than this for any realistic number of nodes,
+	// deeper input is considered corrupt rather than recursed into
+	serialMaxDepth = 128
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+//Write the tree to the writer in the versioned binary format
+func (t *AVLTree) WriteTo(w io.Writer) (n int64, err error) {
+	if err = t.storeErr(); err != nil {
+		return
+	}
+	defer t.finishStore(&err)
+
+	counter := &countingWriter{w: w}
+	crc := crc32.New(crcTable)
+	buf := bufio.NewWriter(io.MultiWriter(counter, crc))
+
+	var scratch [binary.MaxVarintLen64]byte
+	writeBytes := func(b []byte) {
+		buf.Write(scratch[:binary.PutUvarint(scratch[:], uint64(len(b)))])
+		buf.Write(b)
+	}
+
+	buf.Write(serialMagic)
+	buf.WriteByte(serialVersion)
+
+	//Write the nodes in pre-order
+	var writeNode func(n *node)
+	writeNode = func(n *node) {
+		if n.isPlaceholder() {
+			buf.WriteByte(serialPlaceholder)
+			return
+		}
+
+		buf.WriteByte(serialNode)
+		writeBytes(n.key)
+		writeBytes(n.value)
+		writeNode(n.leftNode)
+		writeNode(n.rightNode)
+	}
+	writeNode(t.trunk)
+
+	t.trunk.rehash(t)
+	writeBytes(t.trunk.hash)
+
+	//The checksum is written after flushing all preceding bytes through it
+	if err = buf.Flush(); err != nil {
+		return counter.n, err
+	}
+	_, err = counter.Write(binary.BigEndian.AppendUint32(nil, crc.Sum32()))
+
+	return counter.n, err
+}
+
+//Read a tree from the reader which was written with WriteTo. The options must match
+// those of the written tree, which is verified by comparing the root hash.
+func ReadTree(r io.Reader, opts ...Option) (tr AVLTree, err error) {
+
+	tr = NewAVLTree(opts...)
+
+	sr := &serialReader{
+		r:   r,
+		crc: crc32.New(crcTable),
+	}
+
+	//Check the magic bytes and version
+	header := sr.readN(uint64(len(serialMagic)) + 1)
+	switch {
+	case sr.err != nil:
+		return tr, sr.err
+	case !bytes.Equal(header[:len(serialMagic)], serialMagic),
+		header[len(serialMagic)] != serialVersion:
+		return tr, errBadFormat
+	}
+
+	//Read the nodes in pre-order, rebuilding heights, sizes, and hashes bottom-up.
+	// Keys must be strictly between the bounds lo and hi given by the ancestors
+	// and each node must be balanced, otherwise the tree is corrupt.
+	var readNode func(parNode *node, lo, hi []byte, depth int) *node
+	readNode = func(parNode *node, lo, hi []byte, depth int) *node {
+
+		flag := sr.readByte()
+		if sr.err != nil || flag == serialPlaceholder {
+			return newNodePlaceholder(parNode)
+		}
+		if flag != serialNode || depth > serialMaxDepth {
+			sr.err = errCorruptTree
+			return newNodePlaceholder(parNode)
+		}
+
+		n := newNodePlaceholder(parNode)
+		n.key = sr.readBytes()
+		n.value = sr.readBytes()
+
+		if sr.err == nil && ((lo != nil && bytes.Compare(n.key, lo) <= 0) ||
+			(hi != nil && bytes.Compare(n.key, hi) >= 0)) {
+			sr.err = errCorruptTree
+		}
+		if sr.err != nil {
+			return newNodePlaceholder(parNode)
+		}
+
+		n.leftNode = readNode(n, lo, n.key, depth+1)
+		n.rightNode = readNode(n, n.key, hi, depth+1)
+		n.updateHeightAndHash(&tr)
+
+		if bal := n.getBalance(); sr.err == nil && (bal > 1 || bal < -1) {
+			sr.err = errCorruptTree
+		}
+
+		return n
+	}
+	trunk := readNode(nil, nil, nil, 0)
+	trunk.rehash(&tr)
+	rootHash := sr.readBytes()
+
+	//Verify the checksum of all bytes read so far
+	sum := sr.crc.Sum32()
+	sr.crc = nil
+	checksum := sr.readN(4)
+	switch {
+	case sr.err != nil:
+		return tr, sr.err
+	case binary.BigEndian.Uint32(checksum) != sum:
+		return tr, errCorruptTree
+	case !bytes.Equal(trunk.hash, rootHash):
+		return tr, errRootMismatch
+	}
+
+	tr.trunk = trunk
+
+	return tr, nil
+}
+
+//Reader for serialized trees which records the first error
+// and checksums the bytes read while crc is set
+type serialReader struct {
+	r   io.Reader
+	crc hash.Hash32
+	err error
+}
+
+//Read n bytes, growing the buffer as bytes arrive
+// so that corrupt lengths cannot cause large allocations
+func (sr *serialReader) readN(n uint64) []byte {
+	if sr.err != nil {
+		return nil
+	}
+
+	if n > math.MaxInt64 {
+		sr.err = errCorruptTree
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, sr.r, int64(n)); err != nil {
+		sr.err = errCorruptTree
+		return nil
+	}
+
+	if sr.crc != nil {
+		sr.crc.Write(buf.Bytes())
+	}
+	return buf.Bytes()
+}
+
+func (sr *serialReader) readByte() byte {
+	b := sr.readN(1)
+	if sr.err != nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (sr *serialReader) ReadByte() (byte, error) {
+	b := sr.readByte()
+	return b, sr.err
+}
+
+//Read uvarint length-prefixed bytes
+func (sr *serialReader) readBytes() []byte {
+	if sr.err != nil {
+		return nil
+	}
+
+	length, err := binary.ReadUvarint(sr)
+	if err != nil {
+		sr.err = errCorruptTree
+		return nil
+	}
+
+	b := sr.readN(length)
+	if b == nil && sr.err == nil {
+		b = []byte{}
+	}
+	return b
+}
+
+//Writer which counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
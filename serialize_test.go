@@ -0,0 +1,106 @@
+package AVL_Tree
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestSerialization(t *testing.T) {
+
+	printErr := func(err error) {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	//Test that a tree is restored with the identical shape, hash, and records
+	roundTripTest := func(tr *AVLTree, opts ...Option) []byte {
+		var buf bytes.Buffer
+		n, err := tr.WriteTo(&buf)
+		printErr(err)
+		if n != int64(buf.Len()) {
+			t.Errorf("bad number of bytes written, expected %v found %v", buf.Len(), n)
+		}
+		serialized := append([]byte{}, buf.Bytes()...)
+
+		restored, err := ReadTree(&buf, opts...)
+		printErr(err)
+
+		if tr.TreeStructure() != restored.TreeStructure() {
+			t.Errorf("bad restored structure, expected\n%v found\n%v", tr.TreeStructure(), restored.TreeStructure())
+		}
+
+		//Heights and hashes are compared throughout the whole tree
+		var compareNodes func(a, b *node)
+		compareNodes = func(a, b *node) {
+			if a.isPlaceholder() || b.isPlaceholder() {
+				if a.isPlaceholder() != b.isPlaceholder() {
+					t.Errorf("bad restored placeholder")
+				}
+				return
+			}
+			if a.height != b.height || a.size != b.size || !bytes.Equal(a.hash, b.hash) {
+				t.Errorf("bad restored node %v", string(a.key))
+			}
+			compareNodes(a.leftNode, b.leftNode)
+			compareNodes(a.rightNode, b.rightNode)
+		}
+		compareNodes(tr.trunk, restored.trunk)
+
+		return serialized
+	}
+
+	//Test an empty tree
+	tr := NewAVLTree()
+	roundTripTest(&tr)
+
+	//Test a tree shaped by random additions and removals
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		key := []byte(fmt.Sprintf("k%03d", rnd.Intn(40)))
+		if rnd.Intn(3) == 0 {
+			tr.Remove(key)
+		} else {
+			printErr(tr.Set(key, []byte(fmt.Sprintf("v%d", i))))
+		}
+	}
+	tr.Set([]byte{}, []byte{})
+	serialized := roundTripTest(&tr)
+
+	//Test a tree using other hashing options
+	legacyTr := NewAVLTree(WithHashScheme(HashSchemeLegacy), WithHasher(SHA256Hasher{}))
+	for i := 0; i < 20; i++ {
+		printErr(legacyTr.Add([]byte(fmt.Sprintf("k%02d", i)), []byte("v")))
+	}
+	legacySerialized := roundTripTest(&legacyTr, WithHashScheme(HashSchemeLegacy), WithHasher(SHA256Hasher{}))
+
+	//Reading with mismatched hashing options must fail
+	if _, err := ReadTree(bytes.NewReader(legacySerialized)); err != errRootMismatch {
+		t.Errorf("expected a root mismatch error, found %v", err)
+	}
+
+	//Test that truncated data fails at every length
+	for length := 0; length < len(serialized); length++ {
+		if _, err := ReadTree(bytes.NewReader(serialized[:length])); err == nil {
+			t.Fatalf("expected an error reading data truncated to %v bytes", length)
+		}
+	}
+
+	//Test that corrupted data fails for every byte
+	for i := range serialized {
+		corrupted := append([]byte{}, serialized...)
+		corrupted[i] ^= 0x01
+		if _, err := ReadTree(bytes.NewReader(corrupted)); err == nil {
+			t.Fatalf("expected an error reading data corrupted at byte %v", i)
+		}
+	}
+
+	//Test that an unsupported version fails
+	unsupported := append([]byte{}, serialized...)
+	unsupported[len(serialMagic)] = serialVersion + 1
+	if _, err := ReadTree(bytes.NewReader(unsupported)); err != errBadFormat {
+		t.Errorf("expected a bad format error, found %v", err)
+	}
+}
@@ -0,0 +1,605 @@
+package AVL_Tree
+
+import (
+	"container/list"
+	"encoding/binary"
+	"errors"
+)
+
+//errors used for node storage
+var errNoNodeDB error = errors.New("Tree has no node database")
+var errCorruptNode error = errors.New("Stored node is corrupt or missing")
+
+//Nodes are saved under IDs counting up from 1, the ID 0 is reserved
+// for the tree's metadata record which references the saved trunk
+// and the IDs with the top bit set are reserved for version records
+const (
+	metaID uint64 = 0
+
+	//Format 1 only holds the next ID and the trunk
+	metaFormatNoVersions byte = 1
+	metaFormat           byte = 2
+
+	//Default number of saved nodes kept resident in memory
+	defaultCacheSize = 10000
+)
+
+//Store for the nodes of a tree backed by a node database. Saved records are
+// never modified, a saved node which is changed in memory is unsaved again
+// and its old record is orphaned to be deleted once the tree is next saved.
+type nodeStore struct {
+	db        NodeDB
+	cacheSize int
+	nextID    uint64 //0 until read from the metadata record
+	orphans   []uint64
+	meta      storeMeta
+	newTree   bool  //the tree was created empty rather than loaded from the database
+	err       error //first error loading a node, the tree must be reloaded after this
+	pins      int   //number of open iterators, nodes are not unloaded while any are open
+
+	//Saved nodes resident in memory, the most recently used at the front
+	lru      *list.List
+	resident map[*node]*list.Element
+}
+
+//Metadata of the saved tree and its versions
+type storeMeta struct {
+	trunk          nodeRef
+	version        int64    //version the saved tree is based on, 0 if none
+	versions       []int64  //saved versions in ascending order
+	versionEndID   uint64   //IDs below this are held by saved versions
+	versionOrphans []uint64 //records held by saved versions orphaned since the latest version
+}
+
+//Panic value used to carry a failed load out of the node functions,
+// it is recovered by the tree function which caused the load
+type storeError struct {
+	err error
+}
+
+func newNodeStore(c config) *nodeStore {
+	if c.db == nil {
+		return nil
+	}
+
+	return &nodeStore{
+		db:        c.db,
+		cacheSize: c.cacheSize,
+		newTree:   true,
+		lru:       list.New(),
+		resident:  make(map[*node]*list.Element),
+	}
+}
+
+//Reference to a saved node as held by its parent, which holds everything
+// needed to use the node in hashing and balancing without loading it
+type nodeRef struct {
+	id     uint64 //0 for a placeholder
+	height int
+	size   int
+	hash   []byte
+}
+
+//Generate the node for a reference, saved nodes are left unloaded
+// until they are accessed
+func (s *nodeStore) newNodeRef(parNode *node, ref nodeRef) *node {
+	out := newNodePlaceholder(parNode)
+	if ref.id == 0 {
+		return out
+	}
+
+	out.id = ref.id
+	out.height = ref.height
+	out.size = ref.size
+	out.hash = ref.hash
+	out.store = s
+
+	return out
+}
+
+//Load the record of an unloaded node from the node database,
+// a failure panics with a storeError
+func (n *node) load() {
+
+	s := n.store
+
+	data, err := s.db.Get(n.id)
+	if err == nil && data == nil {
+		err = errCorruptNode
+	}
+
+	var key, value []byte
+	var left, right nodeRef
+	if err == nil {
+		key, value, left, right, err = decodeNodeRecord(data)
+	}
+	if err != nil {
+		panic(storeError{err})
+	}
+
+	n.key = key
+	n.value = value
+	n.leftNode = s.newNodeRef(n, left)
+	n.rightNode = s.newNodeRef(n, right)
+	n.store = nil
+
+	s.track(n)
+}
+
+//Record the node as saved and resident, most recently used
+func (s *nodeStore) track(n *node) {
+	s.resident[n] = s.lru.PushFront(n)
+}
+
+//Mark the node and its ancestors as recently used
+func (s *nodeStore) touch(n *node) {
+	if s == nil {
+		return
+	}
+	for ; n != nil; n = n.parNode {
+		if elem, ok := s.resident[n]; ok {
+			s.lru.MoveToFront(elem)
+		}
+	}
+}
+
+//Orphan the record of a saved node which is being changed or removed
+func (s *nodeStore) orphan(n *node) {
+	s.orphans = append(s.orphans, n.id)
+	if elem, ok := s.resident[n]; ok {
+		s.lru.Remove(elem)
+		delete(s.resident, n)
+	}
+}
+
+//Unload the least recently used saved nodes until no more than the cache size
+// remain resident. Only saved nodes are resident, and as changing a node changes
+// all of its ancestors, the whole subtree of a resident node is saved as well.
+func (s *nodeStore) trim() {
+	for s.lru.Len() > s.cacheSize {
+		s.unload(s.lru.Back().Value.(*node))
+	}
+}
+
+//Unload the node and all resident nodes in its subtree
+func (s *nodeStore) unload(n *node) {
+
+	var forget func(n *node)
+	forget = func(n *node) {
+		if n.store != nil || n.key == nil { //unloaded or placeholder
+			return
+		}
+		s.lru.Remove(s.resident[n])
+		delete(s.resident, n)
+		forget(n.leftNode)
+		forget(n.rightNode)
+	}
+	forget(n)
+
+	n.key = nil
+	n.value = nil
+	n.leftNode = nil
+	n.rightNode = nil
+	n.store = s
+}
+
+//Save all unsaved nodes of the subtree in post-order, so that child
+// references are saved before their parents
+func (s *nodeStore) saveNode(n *node) error {
+	if n.id != 0 || n.key == nil { //saved, unloaded, or placeholder
+		return nil
+	}
+
+	if err := s.saveNode(n.leftNode); err != nil {
+		return err
+	}
+	if err := s.saveNode(n.rightNode); err != nil {
+		return err
+	}
+
+	if err := s.db.Put(s.nextID, encodeNodeRecord(n)); err != nil {
+		return err
+	}
+	n.id = s.nextID
+	s.nextID++
+	s.track(n)
+
+	return nil
+}
+
+//Read the metadata record if it has not been read yet.
+// A database without a metadata record holds an empty tree.
+func (s *nodeStore) ready() error {
+	if s.nextID != 0 {
+		return nil
+	}
+
+	data, err := s.db.Get(metaID)
+	switch {
+	case err != nil:
+		return err
+	case data == nil:
+		s.nextID = 1
+		return nil
+	}
+
+	var meta storeMeta
+	rr := &recordReader{buf: data}
+	format := rr.byte()
+	nextID := rr.uvarint()
+	meta.trunk = rr.ref()
+
+	switch format {
+	case metaFormatNoVersions:
+	case metaFormat:
+		meta.version = int64(rr.uvarint())
+		meta.versionEndID = rr.uvarint()
+		meta.versions = make([]int64, rr.count())
+		for i := range meta.versions {
+			meta.versions[i] = int64(rr.uvarint())
+		}
+		meta.versionOrphans = rr.ids()
+	default:
+		rr.err = errCorruptNode
+	}
+
+	if rr.err != nil || nextID == 0 {
+		return errCorruptNode
+	}
+
+	//A new tree continues the IDs of the saved tree rather
+	// than overwriting its records, but is not based on its version
+	if s.newTree {
+		meta.version = 0
+	}
+	s.nextID = nextID
+	s.meta = meta
+
+	return nil
+}
+
+//Write the metadata record
+func (s *nodeStore) writeMeta(meta storeMeta) error {
+	data := []byte{metaFormat}
+	data = binary.AppendUvarint(data, s.nextID)
+	data = appendNodeRef(data, meta.trunk)
+	data = binary.AppendUvarint(data, uint64(meta.version))
+	data = binary.AppendUvarint(data, meta.versionEndID)
+	data = binary.AppendUvarint(data, uint64(len(meta.versions)))
+	for _, version := range meta.versions {
+		data = binary.AppendUvarint(data, uint64(version))
+	}
+	data = appendIDs(data, meta.versionOrphans)
+	return s.db.Put(metaID, data)
+}
+
+//Save the unsaved nodes of the trunk and write the metadata record, saving the tree
+// as a new version if version is not 0. Records orphaned since the last save are
+// deleted once the metadata record is written unless they are held by a saved version,
+// in which case they are recorded as orphaned by the next version.
+func (s *nodeStore) save(trunk *node, version int64) (err error) {
+
+	if err = s.saveNode(trunk); err != nil {
+		return
+	}
+
+	var deletable []uint64
+	for _, id := range s.orphans {
+		if id < s.meta.versionEndID {
+			s.meta.versionOrphans = append(s.meta.versionOrphans, id)
+		} else {
+			deletable = append(deletable, id)
+		}
+	}
+	s.orphans = deletable
+
+	meta := s.meta
+	meta.trunk = newRef(trunk)
+	if version != 0 {
+		if err = s.writeVersion(version, meta.trunk, meta.versionOrphans); err != nil {
+			return
+		}
+		meta.version = version
+		meta.versions = append(meta.versions[:len(meta.versions):len(meta.versions)], version)
+		meta.versionEndID = s.nextID
+		meta.versionOrphans = nil
+	}
+
+	if err = s.writeMeta(meta); err != nil {
+		return
+	}
+	s.meta = meta
+
+	if db, ok := s.db.(syncer); ok {
+		if err = db.Sync(); err != nil {
+			return
+		}
+	}
+
+	s.newTree = false
+
+	return s.deleteOrphans()
+}
+
+//Discard all nodes in memory, and any failed load, before the trunk is replaced.
+// The records orphaned since the tree was saved are still held by the saved tree.
+func (s *nodeStore) reset() {
+	s.orphans = nil
+	s.lru.Init()
+	s.resident = make(map[*node]*list.Element)
+	s.err = nil
+}
+
+//Delete the records orphaned since the tree was last saved
+func (s *nodeStore) deleteOrphans() error {
+	for len(s.orphans) > 0 {
+		if err := s.db.Delete(s.orphans[0]); err != nil {
+			return err
+		}
+		s.orphans = s.orphans[1:]
+	}
+	return nil
+}
+
+/////////////////////////////
+// Tree Functions
+/////////////////////////////
+
+//Load the tree last saved to the node database, which must be passed in with
+// WithNodeDB. Nodes are loaded as they are accessed, the trunk is not loaded yet.
+func LoadAVLTree(opts ...Option) (tr AVLTree, err error) {
+
+	tr = NewAVLTree(opts...)
+	if tr.store == nil {
+		return tr, errNoNodeDB
+	}
+
+	tr.store.newTree = false
+	if err = tr.store.ready(); err != nil {
+		return tr, err
+	}
+	tr.trunk = tr.store.newNodeRef(nil, tr.store.meta.trunk)
+
+	return tr, nil
+}
+
+//Save all nodes changed since the tree was last saved to the node database,
+// replacing the previously saved tree without saving a new version, and return
+// the merkle root hash.
+// Saved nodes may be unloaded from memory and are loaded again when accessed.
+// Trees without a node database keep the save point, which Rollback returns to, in memory.
+func (t *AVLTree) Save() (hash []byte, err error) {
+	if t.inTx() {
+		return nil, errTxOpen
+	}
+	t.trunk.rehash(t)
+	if t.store == nil {
+		t.journal = newJournal(t.trunk)
+		return t.trunk.hash, nil
+	}
+	if err = t.storeErr(); err != nil {
+		return
+	}
+	defer t.finishStore(&err)
+
+	if err = t.store.ready(); err != nil {
+		return
+	}
+	if err = t.store.save(t.trunk, 0); err != nil {
+		return
+	}
+
+	return t.trunk.hash, nil
+}
+
+//Returns the error which previously failed loading a node, if any
+func (t *AVLTree) storeErr() error {
+	if t.store == nil {
+		return nil
+	}
+	return t.store.err
+}
+
+//Returns the error which failed loading a node from the NodeDB, if any.
+// Once a load has failed the tree must be reloaded. Functions with an error
+// return report the error themselves, functions without one such as Rank and
+// TreeStructure return zero values and the error is only reported here.
+func (t *AVLTree) Err() error {
+	return t.storeErr()
+}
+
+//Deferred by tree functions which may load nodes. A failed load is recovered
+// and returned as the error, as the tree may have been left part way through
+// a change the error is kept and returned by all further functions.
+// Otherwise nodes beyond the cache size are unloaded.
+// Functions without an error return pass in new(error) and the error is kept for Err.
+func (t *AVLTree) finishStore(err *error) {
+	if t.store == nil {
+		return
+	}
+
+	if r := recover(); r != nil {
+		*err = t.store.fail(r)
+		return
+	}
+
+	//Nodes are not unloaded during a transaction so that aborting it only
+	// needs to restore the nodes which were changed, or while an iterator
+	// is open, as the iterator holds on to node objects which unloading would replace
+	if !t.inTx() && t.store.pins == 0 {
+		t.store.trim()
+	}
+}
+
+//Deferred by iterator functions which may load nodes, a failed load
+// is kept as by finishStore and returned as the error but no nodes are unloaded
+func (s *nodeStore) finishLoad(err *error) {
+	if s == nil {
+		return
+	}
+
+	if r := recover(); r != nil {
+		*err = s.fail(r)
+	}
+}
+
+//Keep and return the error of a failed load recovered as r,
+// any other panic is passed on
+func (s *nodeStore) fail(r any) error {
+	se, ok := r.(storeError)
+	if !ok {
+		panic(r)
+	}
+	s.err = se.err
+	return se.err
+}
+
+/////////////////////////////
+// Record Encoding
+/////////////////////////////
+
+//Node records hold the uvarint length-prefixed key and value
+// followed by the references to the left and right children
+func encodeNodeRecord(n *node) []byte {
+	data := binary.AppendUvarint(nil, uint64(len(n.key)))
+	data = append(data, n.key...)
+	data = binary.AppendUvarint(data, uint64(len(n.value)))
+	data = append(data, n.value...)
+	data = appendRef(data, n.leftNode)
+	data = appendRef(data, n.rightNode)
+	return data
+}
+
+func decodeNodeRecord(data []byte) (key, value []byte, left, right nodeRef, err error) {
+	rr := &recordReader{buf: data}
+	key = rr.bytes()
+	value = rr.bytes()
+	left = rr.ref()
+	right = rr.ref()
+
+	if rr.err == nil && len(rr.buf) != 0 {
+		rr.err = errCorruptNode
+	}
+	err = rr.err
+	return
+}
+
+//Returns the reference to a saved node or placeholder
+func newRef(n *node) nodeRef {
+	if n.id == 0 {
+		return nodeRef{}
+	}
+	return nodeRef{
+		id:     n.id,
+		height: n.height,
+		size:   n.size,
+		hash:   n.hash,
+	}
+}
+
+func appendRef(data []byte, n *node) []byte {
+	return appendNodeRef(data, newRef(n))
+}
+
+//References hold the uvarint ID, followed for saved nodes by the
+// uvarint height and size and the uvarint length-prefixed hash
+func appendNodeRef(data []byte, ref nodeRef) []byte {
+	data = binary.AppendUvarint(data, ref.id)
+	if ref.id == 0 {
+		return data
+	}
+	data = binary.AppendUvarint(data, uint64(ref.height))
+	data = binary.AppendUvarint(data, uint64(ref.size))
+	data = binary.AppendUvarint(data, uint64(len(ref.hash)))
+	return append(data, ref.hash...)
+}
+
+//Lists of IDs hold the uvarint count followed by each uvarint ID
+func appendIDs(data []byte, ids []uint64) []byte {
+	data = binary.AppendUvarint(data, uint64(len(ids)))
+	for _, id := range ids {
+		data = binary.AppendUvarint(data, id)
+	}
+	return data
+}
+
+//Reader for stored records which records the first error
+type recordReader struct {
+	buf []byte
+	err error
+}
+
+func (rr *recordReader) byte() byte {
+	if rr.err != nil || len(rr.buf) == 0 {
+		rr.err = errCorruptNode
+		return 0
+	}
+	b := rr.buf[0]
+	rr.buf = rr.buf[1:]
+	return b
+}
+
+func (rr *recordReader) uvarint() uint64 {
+	if rr.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(rr.buf)
+	if n <= 0 {
+		rr.err = errCorruptNode
+		return 0
+	}
+	rr.buf = rr.buf[n:]
+	return v
+}
+
+//Read uvarint length-prefixed bytes, copied so that they
+// are never nil and do not hold on to the record
+func (rr *recordReader) bytes() []byte {
+	length := rr.uvarint()
+	if rr.err != nil || length > uint64(len(rr.buf)) {
+		rr.err = errCorruptNode
+		return nil
+	}
+	b := append([]byte{}, rr.buf[:length]...)
+	rr.buf = rr.buf[length:]
+	return b
+}
+
+//Read a count of following items, each of which takes at least a byte
+func (rr *recordReader) count() int {
+	count := rr.uvarint()
+	if count > uint64(len(rr.buf)) {
+		rr.err = errCorruptNode
+		return 0
+	}
+	return int(count)
+}
+
+func (rr *recordReader) ids() []uint64 {
+	ids := make([]uint64, rr.count())
+	for i := range ids {
+		ids[i] = rr.uvarint()
+	}
+	return ids
+}
+
+func (rr *recordReader) ref() (ref nodeRef) {
+	ref.id = rr.uvarint()
+	if ref.id == 0 {
+		return
+	}
+
+	height := rr.uvarint()
+	size := rr.uvarint()
+	ref.hash = rr.bytes()
+
+	//AVL trees are far shallower than the serialization depth limit,
+	// and a subtree holds at least as many nodes as its height
+	if height > serialMaxDepth || size <= height || size > 1<<62 {
+		rr.err = errCorruptNode
+	}
+	ref.height = int(height)
+	ref.size = int(size)
+
+	return
+}
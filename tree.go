@@ -13,13 +13,22 @@ import (
 )
 
 type AVLTree struct {
-	trunk *node
+	trunk     *node
+	config    config
+	mutations uint64     //count of modifications, used to detect modifications during iteration
+	store     *nodeStore //store for the nodes when the tree has a node database, otherwise nil
+	journal   *journal   //changes since the save point of a tree without a node database, nil until saved
 }
 
-func NewAVLTree() AVLTree {
+//Create a new empty tree, options may be passed in to configure the tree
+func NewAVLTree(opts ...Option) AVLTree {
+
+	c := newConfig(opts)
 
 	return AVLTree{
-		trunk: newNodePlaceholder(nil), //trunk node does not contain a parent
+		trunk:  newNodePlaceholder(nil), //trunk node does not contain a parent
+		config: c,
+		store:  newNodeStore(c),
 	}
 }
 
@@ -30,11 +39,17 @@ var errDupVal error = errors.New("Duplicate key found")
 
 //Returns the merkle root hash (hash of the trunk node)
 func (t *AVLTree) GetHash() (hash []byte, err error) {
+	if err = t.storeErr(); err != nil {
+		return
+	}
+	defer t.finishStore(&err)
+
 	if t.trunk.isPlaceholder() {
 		err = errEmptyTree
 		return
 	}
 
+	t.trunk.rehash(t)
 	hash = t.trunk.hash
 
 	return
@@ -42,12 +57,18 @@ func (t *AVLTree) GetHash() (hash []byte, err error) {
 
 //Get a value from the tree from an existing key
 func (t *AVLTree) Get(key []byte) (value []byte, err error) {
+	if err = t.storeErr(); err != nil {
+		return
+	}
+	defer t.finishStore(&err)
+
 	if t.trunk.isPlaceholder() {
 		err = errEmptyTree
 		return
 	}
 
 	matchNode := t.trunk.findNode(key)
+	t.store.touch(matchNode)
 
 	if matchNode.isPlaceholder() {
 		err = errBadKey
@@ -71,7 +92,12 @@ func (t *AVLTree) Set(key, value []byte) error {
 }
 
 //Update a value from the tree for an already existing key
-func (t *AVLTree) Update(key, value []byte) error {
+func (t *AVLTree) Update(key, value []byte) (err error) {
+	if err = t.storeErr(); err != nil {
+		return
+	}
+	defer t.finishStore(&err)
+
 	if t.trunk.isPlaceholder() {
 		return errEmptyTree
 	}
@@ -82,17 +108,28 @@ func (t *AVLTree) Update(key, value []byte) error {
 		return errBadKey
 	}
 
+	matchNode.record(t)
 	matchNode.value = value
 
+	//Update the hash of the node and its parents
+	matchNode.updateHeightBalanceRecursive(t)
+
+	t.mutations++
+
 	return nil
 
 }
 
 //Add a new key-value to the tree for a non-existent key
-func (t *AVLTree) Add(key, value []byte) error {
+func (t *AVLTree) Add(key, value []byte) (err error) {
+	if err = t.storeErr(); err != nil {
+		return
+	}
+	defer t.finishStore(&err)
 
 	if t.trunk.isPlaceholder() {
-		t.trunk = newNodeLeaf(nil, key, value)
+		t.trunk = newNodeLeaf(t, nil, key, value)
+		t.mutations++
 		return nil
 	}
 
@@ -108,20 +145,27 @@ func (t *AVLTree) Add(key, value []byte) error {
 	parNode := insertPH.parNode
 
 	//Give birth
+	parNode.record(t)
 	if insertPH.isLeftChild() {
-		parNode.leftNode = newNodeLeaf(parNode, key, value)
+		parNode.leftNode = newNodeLeaf(t, parNode, key, value)
 	} else {
-		parNode.rightNode = newNodeLeaf(parNode, key, value)
+		parNode.rightNode = newNodeLeaf(t, parNode, key, value)
 	}
 
 	//Update height and balance
 	parNode.updateHeightBalanceRecursive(t)
 
+	t.mutations++
+
 	return nil
 }
 
 //Remove a key-value pair from the tree
-func (t *AVLTree) Remove(key []byte) error {
+func (t *AVLTree) Remove(key []byte) (err error) {
+	if err = t.storeErr(); err != nil {
+		return
+	}
+	defer t.finishStore(&err)
 
 	if t.trunk.isPlaceholder() {
 		return errEmptyTree
@@ -132,15 +176,23 @@ func (t *AVLTree) Remove(key []byte) error {
 		return errBadKey
 	}
 
-	matchNode.remove()
+	//Update height, balance, and hash from the lowest node effected by the removal
+	matchNode.remove(t).updateHeightBalanceRecursive(t)
 
-	//Update height and balance
-	matchNode.updateHeightBalanceRecursive(t)
+	t.mutations++
 
 	return nil
 }
 
-//Returns the tree structure
+//Returns the tree structure, or an empty string if loading a node fails
 func (t *AVLTree) TreeStructure() string {
+	if t.storeErr() != nil {
+		return ""
+	}
+	defer t.finishStore(new(error))
+
+	if t.trunk.isPlaceholder() {
+		return ""
+	}
 	return t.trunk.outputStructure()
 }
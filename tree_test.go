@@ -2,6 +2,8 @@ package AVL_Tree
 
 import (
 	"bytes"
+	"fmt"
+	"math/rand"
 	"testing"
 )
 
@@ -16,7 +18,7 @@ func TestAVLTree(t *testing.T) {
 
 	printErr := func(err error) {
 		if err != nil {
-			t.Errorf(err.Error())
+			t.Error(err)
 		}
 	}
 
@@ -172,7 +174,7 @@ func TestAVLTree(t *testing.T) {
 	//     c  e       e
 
 	//starting hash before removal
-	hash1 := tr.trunk.hash
+	hash1, _ := tr.GetHash()
 
 	//Removal of leafs
 	printErr(tr.Remove([]byte("a")))
@@ -184,7 +186,7 @@ func TestAVLTree(t *testing.T) {
 	heightBalanceNodeTest("e", 0, 0)
 
 	//test to see if the hash has changed
-	if bytes.Compare(tr.trunk.hash, hash1) == 0 {
+	if hash2, _ := tr.GetHash(); bytes.Compare(hash2, hash1) == 0 {
 		t.Errorf("expected hash to change after having deleted files")
 	}
 
@@ -210,3 +212,181 @@ func TestAVLTree(t *testing.T) {
 	retrieveTest("f", "vF", true)
 	retrieveTest("g", "vG", false)
 }
+
+func TestAVLTreeRandomOperations(t *testing.T) {
+
+	//The AVLTree to be tested with, and the expected records held within it
+	tr := NewAVLTree()
+	records := make(map[string]string)
+
+	//Recompute the height and hash of a node from scratch,
+	// checking the stored values, ordering, balance, and parent links along the way
+	var recompute func(n *node, lo, hi []byte) (height int, hash []byte)
+	recompute = func(n *node, lo, hi []byte) (height int, hash []byte) {
+		if n.isPlaceholder() {
+			return -1, nil
+		}
+
+		if (lo != nil && bytes.Compare(n.key, lo) <= 0) || (hi != nil && bytes.Compare(n.key, hi) >= 0) {
+			t.Errorf("bad order for %v", string(n.key))
+		}
+		if n.leftNode.parNode != n || n.rightNode.parNode != n {
+			t.Errorf("bad parent link for a child of %v", string(n.key))
+		}
+
+		leftHeight, leftHash := recompute(n.leftNode, lo, n.key)
+		rightHeight, rightHash := recompute(n.rightNode, n.key, hi)
+
+		height = leftHeight
+		if rightHeight > height {
+			height = rightHeight
+		}
+		height++
+
+		if bal := rightHeight - leftHeight; bal > 1 || bal < -1 {
+			t.Errorf("bad balance for %v found %v", string(n.key), bal)
+		}
+		if n.height != height {
+			t.Errorf("bad height for %v, expected %v found %v", string(n.key), height, n.height)
+		}
+		if size := n.leftNode.size + n.rightNode.size + 1; n.size != size {
+			t.Errorf("bad size for %v, expected %v found %v", string(n.key), size, n.size)
+		}
+
+		hash = tr.config.hashNode(n.key, n.value, height, leftHash, rightHash)
+		return
+	}
+
+	//Test the incremental root hash against a full recomputation
+	// and the tree contents against the expected records
+	checkTree := func(op string) {
+		if !tr.trunk.isTrunk() {
+			t.Fatalf("bad trunk after %v", op)
+		}
+
+		_, expdHash := recompute(tr.trunk, nil, nil)
+		hash, _ := tr.GetHash()
+		if !bytes.Equal(hash, expdHash) {
+			t.Fatalf("bad hash after %v, expected %x found %x", op, expdHash, hash)
+		}
+
+		for key, value := range records {
+			recievedVal, err := tr.Get([]byte(key))
+			if err != nil || string(recievedVal) != value {
+				t.Fatalf("bad value for %v after %v, expected %v found %v", key, op, value, string(recievedVal))
+			}
+		}
+
+		if tr.Size() != len(records) {
+			t.Fatalf("bad size after %v, expected %v found %v", op, len(records), tr.Size())
+		}
+
+		if len(records) == 0 && !tr.trunk.isPlaceholder() {
+			t.Fatalf("expected an empty tree after %v", op)
+		}
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		key := fmt.Sprintf("k%02d", rnd.Intn(64))
+		value := fmt.Sprintf("v%d", i)
+		_, exists := records[key]
+
+		var op string
+		var err error
+		switch rnd.Intn(4) {
+		case 0:
+			op = "add " + key
+			err = tr.Add([]byte(key), []byte(value))
+			if !exists {
+				records[key] = value
+			}
+		case 1:
+			op = "set " + key
+			err = tr.Set([]byte(key), []byte(value))
+			records[key] = value
+		case 2:
+			op = "update " + key
+			err = tr.Update([]byte(key), []byte(value))
+			if exists {
+				records[key] = value
+			}
+		case 3:
+			op = "remove " + key
+			err = tr.Remove([]byte(key))
+			delete(records, key)
+		}
+
+		//Errors are only expected for adding existing keys or
+		// updating and removing non-existent keys
+		expectErr := false
+		switch op[:3] {
+		case "add":
+			expectErr = exists
+		case "upd", "rem":
+			expectErr = !exists
+		}
+		if (err != nil) != expectErr {
+			t.Fatalf("bad error for %v, key exists %v, found %v", op, exists, err)
+		}
+
+		checkTree(op)
+	}
+}
+
+func TestLazyHashing(t *testing.T) {
+
+	//Count the nodes hashed by the tree
+	hashes := 0
+	countingHasher := HasherFunc(func(input []byte) []byte {
+		hashes++
+		return SHA3Hasher{}.Sum(input)
+	})
+
+	tr := NewAVLTree(WithHasher(countingHasher))
+
+	//Count the nodes marked to be hashed, checking that the parents of marked nodes are marked,
+	// and that the hash of each unmarked node is that of its record and children
+	var countDirty func(n *node) int
+	countDirty = func(n *node) int {
+		if n.isPlaceholder() {
+			return 0
+		}
+		if !n.dirty() {
+			expdHash := tr.config.hashNode(n.key, n.value, n.height, n.leftNode.hash, n.rightNode.hash)
+			if n.leftNode.dirty() || n.rightNode.dirty() || !bytes.Equal(n.hash, expdHash) {
+				t.Fatalf("bad hash for %v", string(n.key))
+			}
+			return 0
+		}
+		return countDirty(n.leftNode) + countDirty(n.rightNode) + 1
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		hashes = 0
+		for j := 0; j < rnd.Intn(50); j++ {
+			key := []byte(fmt.Sprintf("k%03d", rnd.Intn(200)))
+			if rnd.Intn(3) == 0 {
+				tr.Remove(key)
+			} else if err := tr.Set(key, []byte(fmt.Sprint(i))); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		//Changes hash nothing until the hash is read, which hashes each marked node once
+		if hashes != 0 {
+			t.Fatalf("expected no hashes while changing, found %v", hashes)
+		}
+		dirty := countDirty(tr.trunk)
+		hashes = 0
+		tr.GetHash()
+		tr.GetHash()
+		if hashes != dirty {
+			t.Fatalf("bad hash count, expected %v found %v", dirty, hashes)
+		}
+		if countDirty(tr.trunk) != 0 {
+			t.Fatalf("expected no marked nodes after reading the hash")
+		}
+	}
+}
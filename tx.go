@@ -0,0 +1,154 @@
+package AVL_Tree
+
+import (
+	"errors"
+)
+
+//errors used for transactions
+var errTxOpen error = errors.New("Tree has an open transaction")
+var errTxDone error = errors.New("Transaction has already been committed or aborted")
+var errTxNested error = errors.New("Transaction has an open nested transaction")
+
+//Transaction of changes to a tree which are either all committed or all aborted.
+// Changes are applied to the tree as they are made, so the transaction reads its own
+// changes, and the original state of each changed node is recorded so that aborting
+// restores the exact prior structure and merkle hash. The tree must only be used
+// through the transaction while it is open.
+type Tx struct {
+	tree    *AVLTree
+	journal *journal
+	done    bool
+}
+
+//Begin a transaction of changes to the tree
+func (t *AVLTree) Begin() *Tx {
+
+	j := newJournal(t.trunk)
+	j.parent = t.journal
+	j.tx = true
+	if t.store != nil {
+		j.orphans = len(t.store.orphans)
+	}
+	t.journal = j
+
+	return &Tx{
+		tree:    t,
+		journal: j,
+	}
+}
+
+//Returns true if the tree has an open transaction
+func (t *AVLTree) inTx() bool {
+	return t.journal != nil && t.journal.tx
+}
+
+//Begin a nested transaction, acting as a savepoint within the transaction. The changes of
+// the nested transaction are kept when it is committed, and are discarded when it is
+// aborted, or when this transaction is aborted. This transaction must not be used while
+// the nested transaction is open.
+func (tx *Tx) Begin() (*Tx, error) {
+	if err := tx.check(); err != nil {
+		return nil, err
+	}
+	return tx.tree.Begin(), nil
+}
+
+//Commit the changes of the transaction, for a nested transaction they are kept
+// by the enclosing transaction which may still abort them
+func (tx *Tx) Commit() error {
+	if err := tx.check(); err != nil {
+		return err
+	}
+
+	//The enclosing journal takes the original state of nodes it has not yet recorded
+	if parent := tx.journal.parent; parent != nil {
+		for n, fields := range tx.journal.nodes {
+			if _, ok := parent.nodes[n]; !ok {
+				parent.nodes[n] = fields
+			}
+		}
+	}
+
+	tx.tree.journal = tx.journal.parent
+	tx.done = true
+
+	return nil
+}
+
+//Abort the transaction, discarding all of its changes
+func (tx *Tx) Abort() error {
+	if err := tx.check(); err != nil {
+		return err
+	}
+
+	tx.tree.restore(tx.journal)
+	tx.tree.journal = tx.journal.parent
+	tx.done = true
+
+	return nil
+}
+
+//Returns an error if the transaction cannot be used
+func (tx *Tx) check() error {
+	switch {
+	case tx.done:
+		return errTxDone
+	case tx.tree.journal != tx.journal:
+		return errTxNested
+	}
+	return nil
+}
+
+/////////////////////////////
+// Tree Functions
+/////////////////////////////
+
+func (tx *Tx) GetHash() (hash []byte, err error) {
+	if err = tx.check(); err != nil {
+		return
+	}
+	return tx.tree.GetHash()
+}
+
+func (tx *Tx) Get(key []byte) (value []byte, err error) {
+	if err = tx.check(); err != nil {
+		return
+	}
+	return tx.tree.Get(key)
+}
+
+func (tx *Tx) Set(key, value []byte) error {
+	if err := tx.check(); err != nil {
+		return err
+	}
+	return tx.tree.Set(key, value)
+}
+
+func (tx *Tx) Add(key, value []byte) error {
+	if err := tx.check(); err != nil {
+		return err
+	}
+	return tx.tree.Add(key, value)
+}
+
+func (tx *Tx) Update(key, value []byte) error {
+	if err := tx.check(); err != nil {
+		return err
+	}
+	return tx.tree.Update(key, value)
+}
+
+func (tx *Tx) Remove(key []byte) error {
+	if err := tx.check(); err != nil {
+		return err
+	}
+	return tx.tree.Remove(key)
+}
+
+//Returns the tree structure, or an empty string if the transaction cannot be used
+func (tx *Tx) TreeStructure() string {
+	if tx.check() != nil {
+		return ""
+	}
+	return tx.tree.TreeStructure()
+}
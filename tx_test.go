@@ -0,0 +1,127 @@
+package AVL_Tree
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+var _ Tree = (*Tx)(nil)
+
+func TestTx(t *testing.T) {
+
+	printErr := func(err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testTx := func(name string, tr *AVLTree) {
+
+		//The serialized tree and structure, which together
+		// hold the exact shape, records, and hash of the tree
+		type treeState struct {
+			bytes     []byte
+			structure string
+		}
+		state := func() treeState {
+			var buf bytes.Buffer
+			_, err := tr.WriteTo(&buf)
+			printErr(err)
+			return treeState{buf.Bytes(), tr.TreeStructure()}
+		}
+		checkState := func(expd treeState, op string) {
+			found := state()
+			if !bytes.Equal(found.bytes, expd.bytes) || found.structure != expd.structure {
+				t.Fatalf("bad %v tree after %v, expected\n%v\nfound\n%v", name, op, expd.structure, found.structure)
+			}
+		}
+
+		for i := 0; i < 32; i++ {
+			printErr(tr.Add([]byte(fmt.Sprintf("k%02d", i)), []byte("a")))
+		}
+
+		//Random changes within transactions, each aborted or committed, along with nested
+		// transactions acting as savepoints. Trees with a node database are saved
+		// regularly, so that saved nodes are unloaded and loaded while changing.
+		rnd := rand.New(rand.NewSource(1))
+		for i := 0; i < 200; i++ {
+			before := state()
+			tx := tr.Begin()
+			for j := 0; j < 20; j++ {
+				key := []byte(fmt.Sprintf("k%02d", rnd.Intn(64)))
+				if rnd.Intn(3) == 0 {
+					tx.Remove(key)
+				} else {
+					printErr(tx.Set(key, []byte(fmt.Sprintf("v%d", i))))
+				}
+
+				recievedVal, err := tx.Get(key)
+				if err == nil && string(recievedVal) != fmt.Sprintf("v%d", i) {
+					t.Fatalf("bad %v value within a transaction, expected v%d found %s", name, i, recievedVal)
+				}
+
+				if rnd.Intn(5) == 0 {
+					savepoint := state()
+					nested, err := tx.Begin()
+					printErr(err)
+					for k := 0; k < 5; k++ {
+						printErr(nested.Set([]byte(fmt.Sprintf("k%02d", rnd.Intn(64))), []byte("nested")))
+					}
+					if _, err = tx.Get(key); err != errTxNested {
+						t.Fatalf("expected a nested transaction error, found %v", err)
+					}
+					if rnd.Intn(2) == 0 {
+						printErr(nested.Abort())
+						checkState(savepoint, "aborting a nested transaction")
+					} else {
+						printErr(nested.Commit())
+					}
+				}
+			}
+
+			if rnd.Intn(2) == 0 {
+				printErr(tx.Abort())
+				checkState(before, fmt.Sprintf("abort %v", i))
+			} else {
+				after := state()
+				printErr(tx.Commit())
+				checkState(after, fmt.Sprintf("commit %v", i))
+			}
+			if err := tx.Set([]byte("k00"), nil); err != errTxDone {
+				t.Fatalf("expected a transaction done error, found %v", err)
+			}
+
+			if i%10 == 0 {
+				_, err := tr.Save()
+				printErr(err)
+			}
+		}
+
+		//A failed add midway leaves none of the batch applied
+		before := state()
+		tx := tr.Begin()
+		if _, err := tr.Save(); err != errTxOpen {
+			t.Errorf("expected a transaction open error, found %v", err)
+		}
+		for _, key := range []string{"new1", "new2", "new1", "new3"} {
+			if err := tx.Add([]byte(key), []byte("b")); err != nil {
+				if err != errDupVal {
+					t.Errorf("expected a duplicate value error, found %v", err)
+				}
+				printErr(tx.Abort())
+				break
+			}
+		}
+		checkState(before, "aborting a failed batch")
+		if _, err := tr.Get([]byte("new1")); err != errBadKey {
+			t.Errorf("expected a bad key error after aborting, found %v", err)
+		}
+	}
+
+	memTr := NewAVLTree()
+	testTx("in memory", &memTr)
+	dbTr := NewAVLTree(WithNodeDB(NewMemNodeDB()), WithCacheSize(4))
+	testTx("node database", &dbTr)
+}
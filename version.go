@@ -0,0 +1,414 @@
+package AVL_Tree
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+//errors used for versions
+var errNoVersion error = errors.New("Version not found")
+var errVersionConflict error = errors.New("Tree is not based on a saved version")
+var errVersionInUse error = errors.New("Version is the latest version or the tree is based on it")
+
+//Version records are saved under the version with the top bit set, and hold
+// the uvarint ID following the version's records, the reference to the version's
+// trunk, and the list of records held by earlier versions orphaned by the version
+func versionID(version int64) uint64 {
+	return 1<<63 | uint64(version)
+}
+
+func (s *nodeStore) writeVersion(version int64, trunk nodeRef, orphans []uint64) error {
+	data := binary.AppendUvarint(nil, s.nextID)
+	data = appendNodeRef(data, trunk)
+	data = appendIDs(data, orphans)
+	return s.db.Put(versionID(version), data)
+}
+
+func (s *nodeStore) readVersion(version int64) (endID uint64, trunk nodeRef, orphans []uint64, err error) {
+	if !s.hasVersion(version) {
+		err = errNoVersion
+		return
+	}
+
+	data, err := s.db.Get(versionID(version))
+	if err != nil {
+		return
+	}
+
+	rr := &recordReader{buf: data}
+	endID = rr.uvarint()
+	trunk = rr.ref()
+	orphans = rr.ids()
+
+	if rr.err == nil && (data == nil || len(rr.buf) != 0) {
+		rr.err = errCorruptNode
+	}
+	err = rr.err
+	return
+}
+
+func (s *nodeStore) hasVersion(version int64) bool {
+	for _, v := range s.meta.versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+//Returns the latest saved version, or 0 if no version has been saved
+func (s *nodeStore) latestVersion() int64 {
+	if len(s.meta.versions) == 0 {
+		return 0
+	}
+	return s.meta.versions[len(s.meta.versions)-1]
+}
+
+//Delete the version, freeing the records which are not held by any other version.
+// As versions are saved in a line, and saved records are never changed, a record is
+// held by a consecutive run of versions. Each record which has been orphaned is listed
+// by the first version after the run which holds it. Deleting a version moves its
+// orphans to the next version, and frees the orphans of the next version which were
+// saved after the previous version, as the deleted version was the only one holding them.
+func (s *nodeStore) deleteVersion(version int64) error {
+
+	if version == s.latestVersion() || version == s.meta.version {
+		return errVersionInUse
+	}
+	_, _, orphans, err := s.readVersion(version)
+	if err != nil {
+		return err
+	}
+
+	//Find the versions before and after the version, there is always a later version
+	var prevVersion, nextVersion int64
+	var versions []int64
+	for _, v := range s.meta.versions {
+		switch {
+		case v < version:
+			prevVersion = v
+		case v > version && nextVersion == 0:
+			nextVersion = v
+		}
+		if v != version {
+			versions = append(versions, v)
+		}
+	}
+
+	//Records below the end ID of the previous version are held by it
+	var prevEndID uint64
+	if prevVersion != 0 {
+		if prevEndID, _, _, err = s.readVersion(prevVersion); err != nil {
+			return err
+		}
+	}
+
+	nextEndID, nextTrunk, nextOrphans, err := s.readVersion(nextVersion)
+	if err != nil {
+		return err
+	}
+
+	var freed []uint64
+	for _, id := range nextOrphans {
+		if id < prevEndID {
+			orphans = append(orphans, id)
+		} else {
+			freed = append(freed, id)
+		}
+	}
+
+	//The next version is written with its new orphans before the version is removed, and the
+	// records are freed last, should this be interrupted records are left unreferenced rather
+	// than freed while held. The end ID is kept with the next version's record as it is written.
+	data := binary.AppendUvarint(nil, nextEndID)
+	data = appendNodeRef(data, nextTrunk)
+	data = appendIDs(data, orphans)
+	if err = s.db.Put(versionID(nextVersion), data); err != nil {
+		return err
+	}
+
+	meta := s.meta
+	meta.versions = versions
+	if err = s.writeMeta(meta); err != nil {
+		return err
+	}
+	s.meta = meta
+	if db, ok := s.db.(syncer); ok {
+		if err = db.Sync(); err != nil {
+			return err
+		}
+	}
+
+	if err = s.db.Delete(versionID(version)); err != nil {
+		return err
+	}
+	for _, id := range freed {
+		if err = s.db.Delete(id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//Delete the versions which are not kept by the retention policy
+func (s *nodeStore) pruneVersions(policy RetentionPolicy) error {
+	if policy.KeepRecent <= 0 && policy.KeepEvery <= 0 {
+		return nil
+	}
+
+	latest := s.latestVersion()
+	for _, version := range append([]int64{}, s.meta.versions...) {
+		switch {
+		case policy.KeepRecent > 0 && version > latest-policy.KeepRecent,
+			policy.KeepEvery > 0 && version%policy.KeepEvery == 0,
+			version == latest,
+			version == s.meta.version:
+			continue
+		}
+		if err := s.deleteVersion(version); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//Discard the versions saved after the version, so that a new version may be saved
+// from it. All records from the end ID of the version up to the end ID of the latest
+// version were saved after the version, and are only held by the discarded versions.
+func (s *nodeStore) discardVersionsAfter(version int64) error {
+	endID, _, _, err := s.readVersion(version)
+	if err != nil {
+		return err
+	}
+
+	var versions, discarded []int64
+	for _, v := range s.meta.versions {
+		if v > version {
+			discarded = append(discarded, v)
+		} else {
+			versions = append(versions, v)
+		}
+	}
+
+	//The metadata is written before the records are deleted, should this be
+	// interrupted the records are left unreferenced rather than deleted while held
+	oldEndID := s.meta.versionEndID
+	meta := s.meta
+	meta.versions = versions
+	meta.versionEndID = endID
+	if err = s.writeMeta(meta); err != nil {
+		return err
+	}
+	s.meta = meta
+	if db, ok := s.db.(syncer); ok {
+		if err = db.Sync(); err != nil {
+			return err
+		}
+	}
+
+	for _, v := range discarded {
+		if err = s.db.Delete(versionID(v)); err != nil {
+			return err
+		}
+	}
+	for id := endID; id < oldEndID; id++ {
+		if err = s.db.Delete(id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//Delete the records of the saved subtree which are not held by a saved version,
+// all records below the ID of a version's record are held by saved versions
+func (s *nodeStore) deleteUnversioned(ref nodeRef) error {
+	if ref.id < s.meta.versionEndID {
+		return nil
+	}
+
+	data, err := s.db.Get(ref.id)
+	if err != nil {
+		return err
+	}
+	_, _, left, right, err := decodeNodeRecord(data)
+	if err != nil {
+		return err
+	}
+
+	if err = s.deleteUnversioned(left); err != nil {
+		return err
+	}
+	if err = s.deleteUnversioned(right); err != nil {
+		return err
+	}
+	return s.db.Delete(ref.id)
+}
+
+/////////////////////////////
+// Tree Functions
+/////////////////////////////
+
+//Save the tree to the node database as a new immutable version, returning the version
+// number, counting up from 1, and the merkle root hash. Saved versions share the
+// records of unchanged nodes, as changed nodes are saved as new records.
+// If the tree is based on an earlier version, loaded with LoadVersion, the versions
+// saved after it are discarded and the new version follows it.
+// Generates an error if the tree is not based on a saved version while versions exist.
+// Versions which are not kept by the tree's retention policy are then deleted, should this
+// fail the error is returned along with the saved version.
+func (t *AVLTree) SaveVersion() (version int64, hash []byte, err error) {
+	if t.store == nil {
+		return 0, nil, errNoNodeDB
+	}
+	if t.inTx() {
+		return 0, nil, errTxOpen
+	}
+	if err = t.storeErr(); err != nil {
+		return
+	}
+	defer t.finishStore(&err)
+
+	s := t.store
+	if err = s.ready(); err != nil {
+		return
+	}
+
+	switch {
+	case s.meta.version == s.latestVersion():
+	case s.hasVersion(s.meta.version):
+		if err = s.discardVersionsAfter(s.meta.version); err != nil {
+			return
+		}
+	default:
+		return 0, nil, errVersionConflict
+	}
+
+	version = s.latestVersion() + 1
+	t.trunk.rehash(t)
+	if err = s.save(t.trunk, version); err != nil {
+		return 0, nil, err
+	}
+
+	//Versions not pruned due to an error are pruned with the next version
+	return version, t.trunk.hash, s.pruneVersions(t.config.retention)
+}
+
+//Get a value from the tree as it was saved in the version,
+// the records are read directly from the node database
+func (t *AVLTree) GetVersioned(key []byte, version int64) (value []byte, err error) {
+	if t.store == nil {
+		return nil, errNoNodeDB
+	}
+
+	s := t.store
+	if err = s.ready(); err != nil {
+		return
+	}
+
+	_, ref, _, err := s.readVersion(version)
+	if err != nil {
+		return
+	}
+	if ref.id == 0 {
+		return nil, errEmptyTree
+	}
+
+	for ref.id != 0 {
+		data, err := s.db.Get(ref.id)
+		if err != nil {
+			return nil, err
+		}
+		nodeKey, nodeValue, left, right, err := decodeNodeRecord(data)
+		if err != nil {
+			return nil, err
+		}
+
+		//Compare(a,b) will be 0 if a==b, -1 if a < b, and +1 if a > b
+		switch bytes.Compare(key, nodeKey) {
+		case 0:
+			return nodeValue, nil
+		case -1:
+			ref = left
+		case 1:
+			ref = right
+		}
+	}
+
+	return nil, errBadKey
+}
+
+//Reset the tree to the saved version, discarding all changes since the tree was
+// last saved. The tree is then based on the version, while later versions remain
+// saved and readable until a new version is saved from the tree, which discards them.
+// As the tree is loaded again this also recovers a tree which has failed to load a node.
+func (t *AVLTree) LoadVersion(version int64) (err error) {
+	if t.store == nil {
+		return errNoNodeDB
+	}
+	if t.inTx() {
+		return errTxOpen
+	}
+
+	s := t.store
+	if err = s.ready(); err != nil {
+		return
+	}
+
+	_, trunk, _, err := s.readVersion(version)
+	if err != nil {
+		return
+	}
+
+	meta := s.meta
+	meta.trunk = trunk
+	meta.version = version
+	meta.versionOrphans = nil
+	if err = s.writeMeta(meta); err != nil {
+		return
+	}
+	if db, ok := s.db.(syncer); ok {
+		if err = db.Sync(); err != nil {
+			return
+		}
+	}
+
+	//The previously saved tree, including the records orphaned since it was
+	// saved, is no longer referenced and any of its records not held by a version
+	// can be deleted. Should this fail they remain unreferenced in the database.
+	oldTrunk := s.meta.trunk
+	s.meta = meta
+	s.newTree = false
+	s.reset()
+
+	t.trunk = s.newNodeRef(nil, trunk)
+	t.mutations++
+
+	return s.deleteUnversioned(oldTrunk)
+}
+
+//Delete the saved version, freeing the records of nodes which are not held by any other
+// version. Generates an error for the latest version and the version the tree is based on.
+func (t *AVLTree) DeleteVersion(version int64) (err error) {
+	if t.store == nil {
+		return errNoNodeDB
+	}
+	if err = t.store.ready(); err != nil {
+		return
+	}
+	return t.store.deleteVersion(version)
+}
+
+//Returns the saved versions in ascending order
+func (t *AVLTree) Versions() (versions []int64, err error) {
+	if t.store == nil {
+		return nil, errNoNodeDB
+	}
+	if err = t.store.ready(); err != nil {
+		return
+	}
+	return append([]int64{}, t.store.meta.versions...), nil
+}
@@ -0,0 +1,407 @@
+package AVL_Tree
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestVersions(t *testing.T) {
+
+	printErr := func(err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db := NewMemNodeDB()
+	tr := NewAVLTree(WithNodeDB(db))
+
+	//Expected records and hash of each saved version
+	versionRecords := make(map[int64]map[string]string)
+	versionHashes := make(map[int64][]byte)
+	records := make(map[string]string)
+
+	saveVersion := func() int64 {
+		version, hash, err := tr.SaveVersion()
+		printErr(err)
+		expdHash, _ := tr.GetHash()
+		if !bytes.Equal(hash, expdHash) {
+			t.Errorf("bad hash for version %v, expected %x found %x", version, expdHash, hash)
+		}
+
+		versionRecords[version] = make(map[string]string)
+		for key, value := range records {
+			versionRecords[version][key] = value
+		}
+		versionHashes[version] = hash
+		return version
+	}
+
+	//Test each saved version against its expected records
+	checkVersions := func(name string) {
+		for version, expdRecords := range versionRecords {
+			for i := 0; i < 40; i++ {
+				key := fmt.Sprintf("k%02d", i)
+				value, err := tr.GetVersioned([]byte(key), version)
+				expdValue, exists := expdRecords[key]
+				if string(value) != expdValue || (err == nil) != exists {
+					t.Errorf("bad value for %v at version %v %v, expected %v found %v %v",
+						key, version, name, expdValue, string(value), err)
+				}
+			}
+		}
+	}
+
+	//Test the first version number and reading from an empty version
+	if version := saveVersion(); version != 1 {
+		t.Errorf("bad first version, expected 1 found %v", version)
+	}
+	if _, err := tr.GetVersioned([]byte("k00"), 1); err != errEmptyTree {
+		t.Errorf("expected an empty tree error, found %v", err)
+	}
+
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("k%02d", i)
+		records[key] = "a" + key
+		printErr(tr.Add([]byte(key), []byte(records[key])))
+	}
+	saveVersion()
+
+	for i := 10; i < 30; i++ {
+		key := fmt.Sprintf("k%02d", i)
+		records[key] = "b" + key
+		printErr(tr.Set([]byte(key), []byte(records[key])))
+	}
+	for i := 0; i < 5; i++ {
+		key := fmt.Sprintf("k%02d", i)
+		delete(records, key)
+		printErr(tr.Remove([]byte(key)))
+	}
+
+	//Changes saved without a new version are not held by a version
+	_, err := tr.Save()
+	printErr(err)
+	checkVersions("after save")
+
+	saveVersion()
+	checkVersions("after saving")
+
+	//Test loading an earlier version
+	latestHash, _ := tr.GetHash()
+	records = versionRecords[2]
+	printErr(tr.LoadVersion(2))
+
+	hash, _ := tr.GetHash()
+	if !bytes.Equal(hash, versionHashes[2]) {
+		t.Errorf("bad hash after loading version 2, expected %x found %x", versionHashes[2], hash)
+	}
+	for key, value := range records {
+		recievedVal, err := tr.Get([]byte(key))
+		if err != nil || string(recievedVal) != value {
+			t.Errorf("bad value for %v after loading version 2, expected %v found %v", key, value, string(recievedVal))
+		}
+	}
+
+	//Discarding the unsaved changes leaves no extra records
+	recordCount := db.Len()
+	printErr(tr.Add([]byte("k99"), []byte("v")))
+	_, err = tr.Save()
+	printErr(err)
+	printErr(tr.LoadVersion(3))
+	if db.Len() != recordCount {
+		t.Errorf("bad record count after loading version 3, expected %v found %v", recordCount, db.Len())
+	}
+
+	hash, _ = tr.GetHash()
+	if !bytes.Equal(hash, latestHash) {
+		t.Errorf("bad hash after loading version 3, expected %x found %x", latestHash, hash)
+	}
+	records = versionRecords[3]
+	checkVersions("after loading")
+
+	//Test loading the tree and its versions from the database
+	tr, err = LoadAVLTree(WithNodeDB(db))
+	printErr(err)
+	versions, err := tr.Versions()
+	printErr(err)
+	if fmt.Sprint(versions) != "[1 2 3]" {
+		t.Errorf("bad versions, expected [1 2 3] found %v", versions)
+	}
+	checkVersions("after reloading")
+
+	if version := saveVersion(); version != 4 {
+		t.Errorf("bad version after reloading, expected 4 found %v", version)
+	}
+
+	//Saving from an earlier version discards the later versions
+	printErr(tr.LoadVersion(2))
+	records = make(map[string]string)
+	for key, value := range versionRecords[2] {
+		records[key] = value
+	}
+	delete(versionRecords, 3)
+	delete(versionRecords, 4)
+
+	delete(records, "k05")
+	printErr(tr.Remove([]byte("k05")))
+	if version := saveVersion(); version != 3 {
+		t.Errorf("bad version after saving from version 2, expected 3 found %v", version)
+	}
+	versions, err = tr.Versions()
+	printErr(err)
+	if fmt.Sprint(versions) != "[1 2 3]" {
+		t.Errorf("bad versions after saving from version 2, expected [1 2 3] found %v", versions)
+	}
+	checkVersions("after saving from an earlier version")
+	checkVersionRecords(t, &tr, db)
+
+	if version := saveVersion(); version != 4 {
+		t.Errorf("bad version after saving from version 2, expected 4 found %v", version)
+	}
+	checkVersionRecords(t, &tr, db)
+
+	//A new tree over the database is not based on the latest version
+	newTr := NewAVLTree(WithNodeDB(db))
+	if _, _, err = newTr.SaveVersion(); err != errVersionConflict {
+		t.Errorf("expected a version conflict error for a new tree, found %v", err)
+	}
+
+	//Test missing versions and trees without a node database
+	if _, err = tr.GetVersioned([]byte("k10"), 5); err != errNoVersion {
+		t.Errorf("expected a no version error, found %v", err)
+	}
+	if err = tr.LoadVersion(0); err != errNoVersion {
+		t.Errorf("expected a no version error, found %v", err)
+	}
+	memTr := NewAVLTree()
+	if _, _, err = memTr.SaveVersion(); err != errNoNodeDB {
+		t.Errorf("expected a no node database error, found %v", err)
+	}
+}
+
+//Test that the node database holds exactly the records held by the saved versions
+// and the saved tree, along with the version records and the metadata record
+func checkVersionRecords(t *testing.T, tr *AVLTree, db *MemNodeDB) {
+
+	expdIDs := map[uint64]bool{metaID: true}
+
+	var hold func(ref nodeRef)
+	hold = func(ref nodeRef) {
+		if ref.id == 0 || expdIDs[ref.id] {
+			return
+		}
+		expdIDs[ref.id] = true
+
+		data, _ := db.Get(ref.id)
+		_, _, left, right, err := decodeNodeRecord(data)
+		if err != nil {
+			t.Fatalf("bad record %v held by the tree, %v", ref.id, err)
+		}
+		hold(left)
+		hold(right)
+	}
+
+	s := tr.store
+	hold(s.meta.trunk)
+	for _, version := range s.meta.versions {
+		expdIDs[versionID(version)] = true
+		_, trunk, _, err := s.readVersion(version)
+		if err != nil {
+			t.Fatalf("bad version record %v, %v", version, err)
+		}
+		hold(trunk)
+	}
+
+	for id := range db.records {
+		if !expdIDs[id] {
+			t.Errorf("record %v is not held by the tree", id)
+		}
+	}
+	if len(db.records) != len(expdIDs) {
+		t.Errorf("bad record count, expected %v found %v", len(expdIDs), len(db.records))
+	}
+}
+
+func TestVersionsRandomOperations(t *testing.T) {
+
+	printErr := func(err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//Versions are saved from a tree with a small cache, so that saved
+	// nodes are regularly unloaded and loaded again while changing
+	tr := NewAVLTree(WithNodeDB(NewMemNodeDB()), WithCacheSize(8))
+	records := make(map[string]string)
+	versionRecords := make(map[int64]map[string]string)
+
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 3000; i++ {
+		key := fmt.Sprintf("k%02d", rnd.Intn(64))
+		value := fmt.Sprintf("v%d", i)
+
+		if rnd.Intn(3) == 0 {
+			if tr.Remove([]byte(key)) == nil {
+				delete(records, key)
+			}
+		} else {
+			printErr(tr.Set([]byte(key), []byte(value)))
+			records[key] = value
+		}
+
+		if i%100 == 0 {
+			version, _, err := tr.SaveVersion()
+			printErr(err)
+			versionRecords[version] = make(map[string]string)
+			for key, value := range records {
+				versionRecords[version][key] = value
+			}
+		}
+	}
+
+	for version, expdRecords := range versionRecords {
+		for i := 0; i < 64; i++ {
+			key := fmt.Sprintf("k%02d", i)
+			value, err := tr.GetVersioned([]byte(key), version)
+			expdValue, exists := expdRecords[key]
+			if string(value) != expdValue || (err == nil) != exists {
+				t.Errorf("bad value for %v at version %v, expected %v found %v %v",
+					key, version, expdValue, string(value), err)
+			}
+		}
+	}
+}
+
+func TestDeleteVersion(t *testing.T) {
+
+	printErr := func(err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db := NewMemNodeDB()
+	tr := NewAVLTree(WithNodeDB(db), WithCacheSize(8))
+	records := make(map[string]string)
+	versionRecords := make(map[int64]map[string]string)
+
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 3000; i++ {
+		key := fmt.Sprintf("k%02d", rnd.Intn(64))
+		value := fmt.Sprintf("v%d", i)
+
+		if rnd.Intn(3) == 0 {
+			if tr.Remove([]byte(key)) == nil {
+				delete(records, key)
+			}
+		} else {
+			printErr(tr.Set([]byte(key), []byte(value)))
+			records[key] = value
+		}
+
+		if i%50 != 0 {
+			continue
+		}
+		version, _, err := tr.SaveVersion()
+		printErr(err)
+		versionRecords[version] = make(map[string]string)
+		for key, value := range records {
+			versionRecords[version][key] = value
+		}
+
+		//Delete a random earlier version
+		versions, _ := tr.Versions()
+		if deleteVersion := versions[rnd.Intn(len(versions))]; deleteVersion != version {
+			printErr(tr.DeleteVersion(deleteVersion))
+			delete(versionRecords, deleteVersion)
+		}
+		checkVersionRecords(t, &tr, db)
+	}
+
+	for version, expdRecords := range versionRecords {
+		for i := 0; i < 64; i++ {
+			key := fmt.Sprintf("k%02d", i)
+			value, err := tr.GetVersioned([]byte(key), version)
+			expdValue, exists := expdRecords[key]
+			if string(value) != expdValue || (err == nil) != exists {
+				t.Errorf("bad value for %v at version %v, expected %v found %v %v",
+					key, version, expdValue, string(value), err)
+			}
+		}
+	}
+
+	//Test deleting versions in use
+	versions, _ := tr.Versions()
+	latest := versions[len(versions)-1]
+	if err := tr.DeleteVersion(latest); err != errVersionInUse {
+		t.Errorf("expected a version in use error for the latest version, found %v", err)
+	}
+	printErr(tr.LoadVersion(versions[0]))
+	if err := tr.DeleteVersion(versions[0]); err != errVersionInUse {
+		t.Errorf("expected a version in use error for the loaded version, found %v", err)
+	}
+	if err := tr.DeleteVersion(latest + 1); err != errNoVersion {
+		t.Errorf("expected a no version error, found %v", err)
+	}
+
+	//Saving from the loaded version discards the later versions, along with
+	// the records which only they held after the deletions above
+	version, _, err := tr.SaveVersion()
+	printErr(err)
+	if version != versions[0]+1 {
+		t.Errorf("bad version after saving from version %v, found %v", versions[0], version)
+	}
+	checkVersionRecords(t, &tr, db)
+}
+
+func TestRetentionPolicy(t *testing.T) {
+
+	printErr := func(err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db := NewMemNodeDB()
+	tr := NewAVLTree(WithNodeDB(db), WithRetention(RetentionPolicy{KeepRecent: 3, KeepEvery: 5}))
+
+	for i := 0; i < 20; i++ {
+		for j := 0; j < 10; j++ {
+			key := fmt.Sprintf("k%02d", (i*7+j)%32)
+			printErr(tr.Set([]byte(key), []byte(fmt.Sprintf("v%d", i))))
+		}
+		_, _, err := tr.SaveVersion()
+		printErr(err)
+		checkVersionRecords(t, &tr, db)
+	}
+
+	versions, _ := tr.Versions()
+	if fmt.Sprint(versions) != "[5 10 15 18 19 20]" {
+		t.Errorf("bad versions, expected [5 10 15 18 19 20] found %v", versions)
+	}
+
+	//Test policies which only set one of the fields
+	policyTest := func(policy RetentionPolicy, expdVersions string) {
+		db := NewMemNodeDB()
+		tr := NewAVLTree(WithNodeDB(db), WithRetention(policy))
+
+		for i := 0; i < 12; i++ {
+			printErr(tr.Set([]byte(fmt.Sprintf("k%02d", i%5)), []byte(fmt.Sprintf("v%d", i))))
+			_, _, err := tr.SaveVersion()
+			printErr(err)
+			checkVersionRecords(t, &tr, db)
+		}
+
+		versions, _ := tr.Versions()
+		if fmt.Sprint(versions) != expdVersions {
+			t.Errorf("bad versions for %+v, expected %v found %v", policy, expdVersions, versions)
+		}
+	}
+	policyTest(RetentionPolicy{KeepRecent: 2}, "[11 12]")
+	policyTest(RetentionPolicy{KeepEvery: 4}, "[4 8 12]")
+	policyTest(RetentionPolicy{KeepEvery: 5}, "[5 10 12]")
+	policyTest(RetentionPolicy{}, "[1 2 3 4 5 6 7 8 9 10 11 12]")
+}